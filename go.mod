@@ -1,3 +1,8 @@
 module warreth.dev/immich-sync
 
-go 1.23.7
\ No newline at end of file
+go 1.23.7
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -1,30 +1,446 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"text/tabwriter"
+	"time"
 
 	"warreth.dev/immich-sync/pkg/app"
 	"warreth.dev/immich-sync/pkg/config"
 )
 
+// Exit codes of the "once" command
+const (
+	exitOK      = 0 // every album synced without errors
+	exitFatal   = 1 // config, startup or connection error
+	exitPartial = 2 // at least one item failed to sync
+	exitFailed  = 3 // at least one album could not be synced at all
+)
+
+const usage = `Usage: immich-sync [options] [command]
+
+Commands:
+  sync      Run continuously, syncing albums on their schedules (default)
+  once      Sync every album a single time and exit
+  albums    List the albums in Immich
+  status    Show the last recorded run of every configured album
+  verify    Compare every configured album with its Immich album
+  dry-run   Show what a sync would do without changing Immich
+            [--download] inspect new items' content, [--items] list every item
+  retries   Show failed items waiting for a retry and the dead-letter list
+            [retry|clear] [album] retry or forget dead-lettered items
+
+Options may be given before or after the command.
+
+Options:
+`
+
+var configPath = flag.String("config", defaultConfigPath(), "path to the config file (env IMMICH_SYNC_CONFIG)")
+
+// configFlags map command-line flags to the config keys they override
+var configFlags = map[string]string{
+	"api-url":       "apiURL",
+	"debug":         "debug",
+	"workers":       "workers",
+	"album-workers": "albumWorkers",
+	"state-dir":     "stateDir",
+	"http-addr":     "httpAddr",
+}
+
+func init() {
+	flag.String("api-url", "", "Immich API URL, overrides apiURL")
+	flag.Bool("debug", false, "enable debug logging, overrides debug")
+	flag.Int("workers", 0, "concurrent workers per album, overrides workers")
+	flag.Int("album-workers", 0, "concurrent albums, overrides albumWorkers")
+	flag.String("state-dir", "", "directory for sync state, overrides stateDir")
+	flag.String("http-addr", "", "listen address of the status server, e.g. :8080, overrides httpAddr")
+}
+
+func defaultConfigPath() string {
+	if path := os.Getenv(config.EnvConfigPath); path != "" {
+		return path
+	}
+	return "config.json"
+}
+
+// globalFlag forwards a global option given after the command to the top-level flag set
+type globalFlag struct{ *flag.Flag }
+
+func (g globalFlag) String() string     { return g.Value.String() }
+func (g globalFlag) Set(s string) error { return flag.Set(g.Name, s) }
+
+func (g globalFlag) IsBoolFlag() bool {
+	b, ok := g.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// commandFlags returns a flag set for the arguments of a command that also accepts the global options
+func commandFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = flag.Usage
+	flag.VisitAll(func(f *flag.Flag) {
+		fs.Var(globalFlag{f}, f.Name, f.Usage)
+	})
+	return fs
+}
+
+// parseArgs parses flags anywhere in args, e.g. "once --config x" or "retries clear Holiday --debug",
+// and returns the positional arguments. Everything after "--" is positional.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional
+		}
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			return append(positional, rest...)
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// flagOverrides returns the config keys set on the command line, which take precedence over env and file
+func flagOverrides() map[string]string {
+	overrides := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		if key, ok := configFlags[f.Name]; ok {
+			overrides[key] = f.Value.String()
+		}
+	})
+	return overrides
+}
+
 func main() {
-	fmt.Println(">> Immich Sync Tool <<")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	command := "sync"
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+	}
+	var args []string
+	if flag.NArg() > 1 {
+		args = flag.Args()[1:]
+	}
 
-	cfg, err := config.ReadConfig("config.json")
+	var run func(*app.App) int
+	switch command {
+	case "dry-run":
+		run = dryRunCommand(args)
+		args = nil
+	case "retries":
+		run = retriesCommand(args)
+		args = nil
+	case "sync":
+		run = runSync
+	case "once":
+		run = runOnce
+	case "albums":
+		run = runAlbums
+	case "status":
+		run = runStatus
+	case "verify":
+		run = runVerify
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", command)
+		flag.Usage()
+		os.Exit(exitFatal)
+	}
+	args = parseArgs(commandFlags(command), args)
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments: %v\n", args)
+		flag.Usage()
+		os.Exit(exitFatal)
+	}
+
+	if command == "sync" {
+		fmt.Println(">> Immich Sync Tool <<")
+	}
+
+	overrides := flagOverrides()
+	cfg, err := config.Load(*configPath, overrides)
 	if err != nil {
-		fmt.Printf("Warning: %v\n", err)
-		if os.Getenv("IMMICH_API_KEY") == "" {
-			fmt.Println("Please provide config.json or environment variables.")
-			os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, config.ErrNoConfig) {
+			fmt.Fprintf(os.Stderr, "Please provide %s or environment variables.\n", *configPath)
 		}
+		os.Exit(exitFatal)
 	}
 
 	application, err := app.New(cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing app: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitFatal)
 	}
 
-	application.Run()
+	application.Overrides = overrides
+	os.Exit(run(application))
+}
+
+func runSync(a *app.App) int {
+	a.ConfigPath = *configPath
+	if err := a.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFatal
+	}
+	return exitOK
+}
+
+func runOnce(a *app.App) int {
+	results, err := a.RunOnce()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFatal
+	}
+
+	code := exitOK
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ALBUM\tRESULT\tTOTAL\tADDED\tSKIPPED\tFAILED\tDURATION\tERROR")
+	for _, r := range results {
+		res := r.Result
+		errText := ""
+		if res.Err != nil {
+			errText = res.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\n",
+			albumLabel(res.Title, r.Album.URL), res.Status(), res.Total, res.Added, res.Skipped, res.Failed,
+			res.Duration.Round(time.Second), errText)
+
+		switch res.Status() {
+		case "failed":
+			code = exitFailed
+		case "partial":
+			if code == exitOK {
+				code = exitPartial
+			}
+		}
+	}
+	w.Flush()
+	return code
+}
+
+func runAlbums(a *app.App) int {
+	albums, err := a.ImmichAlbums()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFatal
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tASSETS")
+	for _, album := range albums {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", album.Id, album.AlbumName, album.AssetCount)
+	}
+	w.Flush()
+	return exitOK
+}
+
+func runStatus(a *app.App) int {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ALBUM\tLAST RUN\tRESULT\tTOTAL\tADDED\tSKIPPED\tFAILED\tNEXT RUN\tLAST SUCCESS")
+	for _, s := range a.Status() {
+		if !s.HasRun {
+			fmt.Fprintf(w, "%s\tnever\t-\t-\t-\t-\t-\t-\t-\n", albumLabel(s.Album.AlbumName, s.Album.URL))
+			continue
+		}
+		r := s.Run
+		title := r.Title
+		if s.Album.AlbumName != "" {
+			title = s.Album.AlbumName
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\n",
+			albumLabel(title, s.Album.URL), formatTime(r.LastRun), r.LastResult, r.Total, r.Added, r.Skipped, r.Failed,
+			formatTime(r.NextRun), formatTime(r.LastSuccess))
+	}
+	w.Flush()
+	return exitOK
+}
+
+func runVerify(a *app.App) int {
+	results, err := a.Verify()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFatal
+	}
+
+	code := exitOK
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ALBUM\tIMMICH ID\tSOURCE\tIMMICH\tMISSING\tSKIPPED\tEXTRA\tRESULT")
+	for _, r := range results {
+		result := "ok"
+		switch {
+		case r.Err != nil:
+			result = r.Err.Error()
+			code = exitFailed
+		case !r.OK():
+			result = "mismatch"
+			if code == exitOK {
+				code = exitPartial
+			}
+		}
+		albumId := r.AlbumId
+		if albumId == "" {
+			albumId = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			albumLabel(r.Title, r.Album.URL), albumId, r.SourceCount, r.ImmichCount, r.Missing, r.Skipped, r.Extra, result)
+	}
+	w.Flush()
+	return code
+}
+
+func dryRunCommand(args []string) func(*app.App) int {
+	fs := commandFlags("dry-run")
+	download := fs.Bool("download", false, "download new items to detect videos, embedded dates and duplicate content")
+	listItems := fs.Bool("items", false, "list the planned action of every item")
+	if args := parseArgs(fs, args); len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments: %v\n", args)
+		fs.Usage()
+		os.Exit(exitFatal)
+	}
+
+	return func(a *app.App) int {
+		plans, err := a.DryRun(*download)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitFatal
+		}
+
+		code := exitOK
+		actions := []string{app.PlanAlreadyInAlbum, app.PlanLinkExisting, app.PlanUploadNew, app.PlanSkipStrict, app.PlanSkipVideo, app.PlanSkipTrashed, app.PlanRestoreTrashed, app.PlanSkipDead, app.PlanError}
+		for _, plan := range plans {
+			fmt.Printf("== %s (%s)\n", albumLabel(plan.Title, plan.Album.URL), plan.Album.URL)
+			if plan.Err != nil {
+				fmt.Printf("   error: %v\n\n", plan.Err)
+				code = exitFailed
+				continue
+			}
+			switch {
+			case len(plan.Items) == 0:
+				fmt.Println("   album is empty, nothing to do")
+			case plan.CreateAlbum:
+				fmt.Printf("   Immich album: would be created as %q\n", plan.Title)
+			default:
+				fmt.Printf("   Immich album: %s\n", plan.AlbumId)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			counts := plan.Counts()
+			for _, action := range actions {
+				if counts[action] > 0 {
+					fmt.Fprintf(w, "   %s\t%d\n", action, counts[action])
+				}
+			}
+			if *listItems {
+				for _, item := range plan.Items {
+					fmt.Fprintf(w, "   %s\t%s\t%s\n", item.Action, item.ItemID, item.Reason)
+				}
+			}
+			w.Flush()
+			fmt.Println()
+
+			if counts[app.PlanError] > 0 && code == exitOK {
+				code = exitPartial
+			}
+		}
+		return code
+	}
+}
+
+func retriesCommand(args []string) func(*app.App) int {
+	args = parseArgs(commandFlags("retries"), args)
+	action, ref := "list", ""
+	if len(args) > 0 {
+		switch args[0] {
+		case "list", "retry", "clear":
+			action, args = args[0], args[1:]
+		}
+	}
+	if len(args) > 0 {
+		ref, args = args[0], args[1:]
+	}
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments: %v\n", args)
+		flag.Usage()
+		os.Exit(exitFatal)
+	}
+
+	return func(a *app.App) int {
+		switch action {
+		case "retry":
+			n, err := a.RetryDeadLetters(ref)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitFatal
+			}
+			fmt.Printf("Queued %d dead-lettered items for a retry\n", n)
+			return exitOK
+		case "clear":
+			n, err := a.ClearDeadLetters(ref)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitFatal
+			}
+			fmt.Printf("Cleared %d dead-lettered items\n", n)
+			return exitOK
+		}
+
+		failures, err := a.Failures(ref)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitFatal
+		}
+		if len(failures) == 0 {
+			fmt.Println("No failed items")
+			return exitOK
+		}
+		titles := make(map[string]string)
+		for _, s := range a.Status() {
+			titles[s.Album.URL] = s.Album.AlbumName
+			if titles[s.Album.URL] == "" && s.HasRun {
+				titles[s.Album.URL] = s.Run.Title
+			}
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ALBUM\tITEM\tSTATUS\tATTEMPTS\tCLASS\tLAST FAILED\tNEXT RETRY\tERROR")
+		for _, f := range failures {
+			status := "pending"
+			if f.Dead {
+				status = "dead"
+			}
+			errText := f.Error
+			if runes := []rune(errText); len(runes) > 80 {
+				errText = string(runes[:77]) + "..."
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+				albumLabel(titles[f.Album], f.Album), f.ItemID, status, f.Attempts, f.Class,
+				formatTime(f.LastFailed), formatTime(f.NextRetry), errText)
+		}
+		w.Flush()
+		return exitOK
+	}
+}
+
+func albumLabel(title, url string) string {
+	if title != "" {
+		return title
+	}
+	return url
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
 }
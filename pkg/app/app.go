@@ -1,7 +1,11 @@
 package app
 
 import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"strings"
@@ -9,22 +13,54 @@ import (
 	"time"
 
 	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/cron"
 	"warreth.dev/immich-sync/pkg/googlephotos"
 	"warreth.dev/immich-sync/pkg/immich"
+	"warreth.dev/immich-sync/pkg/metadata"
+	"warreth.dev/immich-sync/pkg/notify"
 	"warreth.dev/immich-sync/pkg/progress"
+	"warreth.dev/immich-sync/pkg/report"
+	"warreth.dev/immich-sync/pkg/scheduler"
+	"warreth.dev/immich-sync/pkg/source"
+	"warreth.dev/immich-sync/pkg/state"
 )
 
 type App struct {
-	Cfg      *config.Config
-	Client   *immich.Client
-	GPClient *googlephotos.Client
-	Logger   *slog.Logger
+	Cfg        *config.Config    // replaced on reload, read it through config()
+	ConfigPath string            // watched by Run for changes when set
+	Overrides  map[string]string // command-line overrides reapplied on reload, see config.Load
+	Client     ImmichAPI
+	GPClient   *googlephotos.Client
+	Sources    map[string]source.Source // keyed by config "source" type
+	State      *state.Store
+	Runs       *state.RunLog
+	Retries    *state.RetryQueue
+	Scheduler  *scheduler.Scheduler // set by Run
+	Logger     *slog.Logger
+
+	metrics  *syncMetrics
+	notifier *notify.Dispatcher
+
+	cfgMu    sync.RWMutex
+	logLevel *slog.LevelVar
+	albumsMu sync.Mutex
+	albums   map[string]config.AlbumConfig // scheduled albums by URL, set by Run
+
+	trackersMu sync.Mutex
+	trackers   map[string]*progress.Tracker // progress of running syncs by album URL
+
+	albumLocksMu sync.Mutex
+	albumLocks   map[string]*sync.Mutex // serializes full syncs and retries of an album, by URL
+
+	healthMu  sync.Mutex
+	lastCheck time.Time // last Immich connection check
+	checkErr  error
 }
 
 func New(cfg *config.Config) (*App, error) {
-	level := slog.LevelInfo
+	level := new(slog.LevelVar)
 	if cfg.Debug {
-		level = slog.LevelDebug
+		level.Set(slog.LevelDebug)
 	}
 	opts := &slog.HandlerOptions{
 		Level: level,
@@ -40,175 +76,534 @@ func New(cfg *config.Config) (*App, error) {
 		},
 	}
 	logger := slog.New(slog.NewTextHandler(os.Stdout, opts))
+	for _, w := range cfg.Warnings {
+		logger.Warn("Config warning", "detail", w)
+	}
+	m := newSyncMetrics()
 	client := immich.NewClient(cfg.ApiURL, cfg.ApiKey)
+	client.OnError = m.immichError
 	gpClient := googlephotos.NewClient(logger)
+	gpClient.SpoolDir = cfg.SpoolDir
+	gpClient.OnRetry = m.googleRetry
+	sources := map[string]source.Source{
+		googlephotos.SourceType: googlephotos.NewSource(gpClient),
+	}
+	for _, ac := range cfg.AllAlbums() {
+		if _, ok := sources[ac.Source]; !ok {
+			return nil, fmt.Errorf("album %s: unknown source %q", ac.URL, ac.Source)
+		}
+	}
+	stateDir := cfg.StateDir
+	if stateDir == "" {
+		stateDir = config.DefaultStateDir
+	}
+	store, err := state.Open(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := state.OpenRunLog(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	retries, err := state.OpenRetryQueue(stateDir)
+	if err != nil {
+		return nil, err
+	}
 	return &App{
 		Cfg:      cfg,
 		Client:   client,
 		GPClient: gpClient,
+		Sources:  sources,
+		State:    store,
+		Runs:     runs,
+		Retries:  retries,
 		Logger:   logger,
+		metrics:  m,
+		notifier: notify.NewDispatcher(logger),
+		logLevel: level,
 	}, nil
 }
 
-func (a *App) Run() {
+// Run is the sync daemon: it schedules every configured album and never returns
+// unless the Immich connection check fails.
+func (a *App) Run() error {
 	a.Logger.Info("Starting Immich Sync")
 
-	id, name, err := a.Client.GetUser()
+	if err := a.Connect(); err != nil {
+		return err
+	}
+
+	cfg := a.config()
+	albums := cfg.AllAlbums()
+	if len(albums) == 0 && a.ConfigPath == "" {
+		a.Logger.Warn("No albums configured")
+		return nil
+	}
+
+	albumWorkers := cfg.AlbumWorkers
+	if albumWorkers < 1 {
+		albumWorkers = 1
+	}
+	a.Logger.Info("Scheduling albums", "count", len(albums), "album_workers", albumWorkers)
+
+	// Each album has its own timer and is rescheduled as soon as its own sync finishes
+	a.albums = make(map[string]config.AlbumConfig, len(albums))
+	a.Scheduler = scheduler.New(albumWorkers, func(url string) time.Time {
+		a.albumsMu.Lock()
+		ac, ok := a.albums[url]
+		a.albumsMu.Unlock()
+		if !ok {
+			// Removed by a reload while waiting for a worker; the scheduler drops it
+			return time.Now()
+		}
+		started := time.Now()
+		result := a.SyncAlbum(ac)
+		next := nextRun(ac, time.Now())
+		a.recordRun(ac, started, result, next)
+		a.Logger.Info("Scheduled next sync", "album", ac.URL, "next_run", next.Format("15:04:05"))
+		return next
+	})
+	a.albumsMu.Lock()
+	for _, ac := range albums {
+		a.albums[ac.URL] = ac
+		a.Scheduler.Add(ac.URL, a.resumeAt(ac))
+		if run, ok := a.Runs.Get(ac.URL); ok {
+			a.metrics.setRunTimes(ac.URL, run) // so the last success survives restarts
+		}
+	}
+	a.albumsMu.Unlock()
+
+	ctx := context.Background()
+	if cfg.HTTPAddr != "" {
+		if err := a.startServer(ctx, cfg.HTTPAddr); err != nil {
+			return fmt.Errorf("failed to start HTTP server: %w", err)
+		}
+	}
+	if a.ConfigPath != "" {
+		go a.watchConfig(ctx)
+	}
+	go a.retryLoop(ctx)
+	a.Scheduler.Run(ctx)
+	return nil
+}
+
+// config returns the current configuration; it is replaced as a whole on reload
+func (a *App) config() *config.Config {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+	return a.Cfg
+}
+
+// Connect verifies the Immich connection and API key
+func (a *App) Connect() error {
+	id, name, err := a.checkImmich()
 	if err != nil {
 		a.Logger.Error("Failed to connect to Immich", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to connect to Immich: %w", err)
 	}
 	a.Logger.Info("Connected to Immich", "user_id", id, "name", name)
+	return nil
+}
 
-	if len(a.Cfg.GooglePhotos) == 0 {
-		a.Logger.Warn("No albums configured")
+// checkImmich calls GetUser and records the outcome for the readiness endpoint
+func (a *App) checkImmich() (string, string, error) {
+	id, name, err := a.Client.GetUser()
+	a.healthMu.Lock()
+	a.lastCheck = time.Now()
+	a.checkErr = err
+	a.healthMu.Unlock()
+	return id, name, err
+}
+
+// setTracker registers the progress tracker of a running sync, or removes it when nil
+func (a *App) setTracker(url string, tracker *progress.Tracker) {
+	a.trackersMu.Lock()
+	defer a.trackersMu.Unlock()
+	if tracker == nil {
+		delete(a.trackers, url)
 		return
 	}
+	if a.trackers == nil {
+		a.trackers = make(map[string]*progress.Tracker)
+	}
+	a.trackers[url] = tracker
+}
 
-	// Initialize schedule
-	nextRun := make(map[string]time.Time)
-	for _, ac := range a.Cfg.GooglePhotos {
-		nextRun[ac.URL] = time.Now()
+// Schedule returns the upcoming album syncs, or nil before Run has started
+func (a *App) Schedule() []scheduler.Entry {
+	if a.Scheduler == nil {
+		return nil
 	}
+	return a.Scheduler.Upcoming()
+}
 
-	albumWorkers := a.Cfg.AlbumWorkers
-	if albumWorkers < 1 {
-		albumWorkers = 1
+// resumeAt returns when an album should first sync after startup. Albums that synced before
+// wait out the rest of their schedule: the recorded next run, or if the schedule has changed
+// since, the next run computed from the last run with the current config.
+func (a *App) resumeAt(ac config.AlbumConfig) time.Time {
+	now := time.Now()
+	last, ok := a.Runs.Get(ac.URL)
+	if !ok || last.LastRun.IsZero() {
+		return now
 	}
+	next := last.NextRun
+	if last.Schedule != scheduleSpec(ac) {
+		next = nextRun(ac, last.LastRun)
+	}
+	if next.After(now) {
+		a.Logger.Info("Resuming schedule", "album", ac.URL, "last_run", last.LastRun.Format(time.RFC3339), "next_run", next.Format(time.RFC3339))
+		return next
+	}
+	return now
+}
 
-	for {
-		// Collect albums due for sync
-		var due []config.GooglePhotosConfig
-		for _, ac := range a.Cfg.GooglePhotos {
-			if time.Now().After(nextRun[ac.URL]) {
-				due = append(due, ac)
-			}
+// recordRun persists the outcome of a sync together with the next scheduled run
+func (a *App) recordRun(ac config.AlbumConfig, started time.Time, result SyncResult, next time.Time) {
+	run, _ := a.Runs.Get(ac.URL)
+	run.LastRun = started
+	run.LastResult = result.Status()
+	run.LastError = ""
+	if result.Err != nil {
+		run.LastError = result.Err.Error()
+	} else {
+		run.LastSuccess = started
+	}
+	if result.Title != "" {
+		run.Title = result.Title
+	}
+	run.Total = result.Total
+	run.Added = result.Added
+	run.Skipped = result.Skipped
+	run.Failed = result.Failed
+	run.Duration = result.Duration
+	run.NextRun = next
+	run.Schedule = scheduleSpec(ac)
+	if err := a.Runs.Put(ac.URL, run); err != nil {
+		a.Logger.Warn("Failed to save run log", "error", err)
+	}
+	a.metrics.observeRun(ac.URL, result, run)
+	a.notifyResult(ac, result)
+	a.writeReport(ac, started, result)
+}
+
+// findAlbumId returns the configured Immich album ID, or the ID of the album named title
+func findAlbumId(ac config.AlbumConfig, title string, albumCache []immich.Album) string {
+	if ac.ImmichAlbumID != "" {
+		return ac.ImmichAlbumID
+	}
+	for _, a := range albumCache {
+		if a.AlbumName == title {
+			return a.Id
 		}
+	}
+	return ""
+}
 
-		if len(due) > 0 {
-			// Fetch album list from Immich once per sync cycle
-			albumCache, err := a.Client.GetAlbums()
-			if err != nil {
-				a.Logger.Warn("Failed to fetch Immich album list", "error", err)
-			}
+// nextRun returns when an album should sync next after the given time
+func nextRun(ac config.AlbumConfig, after time.Time) time.Time {
+	sched, err := ac.ParseSchedule()
+	if err != nil {
+		// Validated when the config is loaded; only reachable for hand-built configs
+		sched = cron.Every(config.DefaultSyncInterval)
+	}
+	return sched.Next(after)
+}
 
-			a.Logger.Info("Processing due albums", "count", len(due), "album_workers", albumWorkers)
-
-			// Process due albums concurrently with bounded concurrency
-			sem := make(chan struct{}, albumWorkers)
-			var wg sync.WaitGroup
-			for _, ac := range due {
-				wg.Add(1)
-				go func(ac config.GooglePhotosConfig) {
-					defer wg.Done()
-					sem <- struct{}{}
-					defer func() { <-sem }()
-					a.processAlbum(ac, albumCache)
-				}(ac)
-			}
-			wg.Wait()
+// scheduleSpec describes the schedule settings of an album, e.g. "every 12h" or "0 3 * * * Europe/Berlin"
+func scheduleSpec(ac config.AlbumConfig) string {
+	if ac.Schedule != "" {
+		return strings.TrimSpace(ac.Schedule + " " + ac.Timezone)
+	}
+	if ac.SyncInterval == "" {
+		return "every " + config.DefaultSyncInterval.String()
+	}
+	return "every " + ac.SyncInterval
+}
 
-			// Schedule next runs
-			for _, ac := range due {
-				interval, err := time.ParseDuration(ac.SyncInterval)
-				if err != nil || interval == 0 {
-					interval = 24 * time.Hour
-				}
-				nextRun[ac.URL] = time.Now().Add(interval)
-				a.Logger.Info("Scheduled next sync", "album", ac.URL, "next_run", nextRun[ac.URL].Format("15:04:05"))
-			}
-		}
+// SyncAlbum runs a single sync of one album, fetching the Immich album list itself
+func (a *App) SyncAlbum(ac config.AlbumConfig) SyncResult {
+	lock := a.albumLock(ac.URL)
+	lock.Lock()
+	defer lock.Unlock()
 
-		time.Sleep(1 * time.Minute)
+	albumCache, err := a.Client.GetAlbums()
+	if err != nil {
+		a.Logger.Warn("Failed to fetch Immich album list", "error", err)
+	}
+	return a.processAlbum(ac, albumCache, nil)
+}
+
+// albumLock returns the mutex held while an album is synced or retried
+func (a *App) albumLock(url string) *sync.Mutex {
+	a.albumLocksMu.Lock()
+	defer a.albumLocksMu.Unlock()
+	if a.albumLocks == nil {
+		a.albumLocks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := a.albumLocks[url]
+	if !ok {
+		lock = &sync.Mutex{}
+		a.albumLocks[url] = lock
+	}
+	return lock
+}
+
+// SyncResult summarizes one sync of an album
+type SyncResult struct {
+	Title       string
+	Total       int
+	Added       int
+	Linked      int // already in Immich or restored from the trash, added to the album
+	Skipped     int
+	Failed      int
+	Err         error // album-level failure, e.g. the source could not be scraped
+	Unreachable bool  // Err is set because the source album could not be fetched
+	Duration    time.Duration
+	Items       []report.Item // outcome of every item, for the run report
+}
+
+// Status returns a short outcome: "success", "partial" (some items failed) or "failed"
+func (r SyncResult) Status() string {
+	switch {
+	case r.Err != nil:
+		return "failed"
+	case r.Failed > 0:
+		return "partial"
+	default:
+		return "success"
 	}
 }
 
 type processResult struct {
 	ID              string
+	ItemID          string
+	URL             string
 	WasUploaded     bool
+	Restored        bool   // taken out of the Immich trash
+	Skipped         string // report outcome of an item that was deliberately not synced, e.g. report.SkippedVideo
 	Error           error
+	ErrorClass      string // what failed, one of the errClass constants
 	BytesDownloaded int64
 	BytesUploaded   int64
+	Duration        time.Duration
+	Record          *state.Record // recorded in the state store once the asset is in the album
+}
+
+// outcome classifies the result for reports and metrics
+func (r processResult) outcome() string {
+	switch {
+	case r.Error != nil:
+		return report.Failed
+	case r.WasUploaded:
+		return report.Added
+	case r.Restored:
+		return report.Restored
+	case r.ID != "":
+		return report.Linked
+	case r.Skipped != "":
+		return r.Skipped
+	default:
+		return report.SkippedExisting
+	}
+}
+
+func (r processResult) reportItem() report.Item {
+	item := report.Item{
+		ItemID:          r.ItemID,
+		URL:             r.URL,
+		Outcome:         r.outcome(),
+		BytesDownloaded: r.BytesDownloaded,
+		BytesUploaded:   r.BytesUploaded,
+		Duration:        r.Duration.Seconds(),
+	}
+	if r.Error != nil {
+		item.Error = r.Error.Error()
+	}
+	return item
+}
+
+// assetLookups lazily fetches Immich's view of the album and of all assets uploaded by this tool.
+// It only runs as a reconciliation fallback for items the state store does not know yet.
+type assetLookups struct {
+	client  ImmichAPI
+	albumId string
+	logger  *slog.Logger
+
+	albumOnce     sync.Once
+	existingFiles map[string]string // baseName (no extension) -> asset ID
+	globalOnce    sync.Once
+	globalAssets  map[string]string // baseName (no extension) -> asset ID
+	trashOnce     sync.Once
+	trashedAssets map[string]string // baseName (no extension) -> asset ID, for assets in the Immich trash
+	trashedIds    map[string]bool
+}
+
+// album returns the assets currently in the Immich album
+func (l *assetLookups) album() map[string]string {
+	l.albumOnce.Do(func() {
+		// Pre-fetch existing album assets for O(1) duplicate detection
+		l.existingFiles = make(map[string]string)
+		if l.albumId != "" {
+			albumDetails, err := l.client.GetAlbum(l.albumId)
+			if err == nil {
+				for _, asset := range albumDetails.Assets {
+					name := asset.OriginalFileName
+					if dot := strings.LastIndex(name, "."); dot != -1 {
+						name = name[:dot]
+					}
+					l.existingFiles[name] = asset.Id
+				}
+				l.logger.Debug("Pre-fetched album assets", "count", len(l.existingFiles))
+			}
+		}
+	})
+	return l.existingFiles
+}
+
+// global returns all assets uploaded by this tool
+func (l *assetLookups) global() map[string]string {
+	l.globalOnce.Do(func() {
+		// Pre-fetch all assets uploaded by this tool globally for O(1) lookup.
+		// Avoids re-downloading and re-uploading files that exist in Immich but not in this album.
+		globalAssets, err := l.client.SearchAssetsByDevice("immich-sync-go")
+		if err != nil {
+			l.logger.Warn("Failed to fetch global assets, will fall back to re-upload for duplicates", "error", err)
+			globalAssets = make(map[string]string)
+		} else {
+			l.logger.Debug("Pre-fetched global assets from Immich", "count", len(globalAssets))
+		}
+		l.globalAssets = globalAssets
+	})
+	return l.globalAssets
+}
+
+// trashed returns the assets uploaded by this tool that are in the Immich trash
+func (l *assetLookups) trashed() map[string]string {
+	l.trashOnce.Do(func() {
+		trashed, err := l.client.SearchTrashedAssetsByDevice("immich-sync-go")
+		if err != nil {
+			l.logger.Warn("Failed to fetch trashed assets, trash policy cannot be applied this run", "error", err)
+			trashed = make(map[string]string)
+		} else {
+			l.logger.Debug("Pre-fetched trashed assets from Immich", "count", len(trashed))
+		}
+		l.trashedAssets = trashed
+		l.trashedIds = make(map[string]bool, len(trashed))
+		for _, id := range trashed {
+			l.trashedIds[id] = true
+		}
+	})
+	return l.trashedAssets
+}
+
+// isTrashed reports whether an asset uploaded by this tool is in the Immich trash
+func (l *assetLookups) isTrashed(assetId string) bool {
+	l.trashed()
+	return l.trashedIds[assetId]
+}
+
+// albumSync holds the per-run context shared by all item workers of an album
+type albumSync struct {
+	cfg        *config.Config // snapshot taken when the sync started, unaffected by reloads
+	ac         config.AlbumConfig
+	src        source.Source
+	albumTitle string
+	lookups    *assetLookups
+	checksums  *checksumBatcher // nil unless checksum dedup is enabled
+	retry      bool             // only retrying failed items, see retryAlbum
 }
 
-func (a *App) processAlbum(ac config.GooglePhotosConfig, albumCache []immich.Album) {
+// processAlbum syncs every item of an album, or with retry set only the items with those IDs
+func (a *App) processAlbum(ac config.AlbumConfig, albumCache []immich.Album, retry map[string]bool) (result SyncResult) {
+	cfg := a.config()
 	logger := a.Logger.With("album_url", ac.URL)
-	logger.Info("Syncing Google Photos Album")
+	if retry != nil {
+		logger.Info("Retrying failed items", "source", ac.Source, "count", len(retry))
+	} else {
+		logger.Info("Syncing album", "source", ac.Source)
+	}
+
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	// Pick up dead letters retried or cleared by the "retries" command; the queue is saved once per run
+	if err := a.Retries.Refresh(); err != nil {
+		logger.Warn("Failed to read retry queue", "error", err)
+	}
+	defer func() {
+		if err := a.Retries.Save(); err != nil {
+			logger.Error("Failed to save retry queue", "error", err)
+		}
+	}()
 
-	album, err := googlephotos.ScrapeAlbum(a.GPClient, ac.URL)
+	src, ok := a.Sources[ac.Source]
+	if !ok {
+		logger.Error("Unknown album source", "source", ac.Source)
+		result.Err = fmt.Errorf("unknown source %q", ac.Source)
+		return result
+	}
+
+	album, err := src.FetchAlbum(ac.URL)
 	if err != nil {
 		logger.Error("Error scraping album", "error", err)
-		return
+		result.Err = fmt.Errorf("error scraping album: %w", err)
+		result.Unreachable = true
+		return result
 	}
 
 	albumTitle := album.Title
 	if ac.AlbumName != "" {
 		albumTitle = ac.AlbumName
 	}
-	logger.Info("Found photos in album", "count", len(album.Photos), "title", albumTitle)
+	result.Title = albumTitle
+	logger.Info("Found photos in album", "count", len(album.Items), "title", albumTitle)
 
-	if len(album.Photos) == 0 {
-		logger.Info("No photos found, skipping")
-		return
+	if retry != nil {
+		album.Items = a.retryItems(ac, album.Items, retry)
 	}
 
-	// Resolve Immich album ID
-	var albumId string
-	if ac.ImmichAlbumID != "" {
-		albumId = ac.ImmichAlbumID
-	} else {
-		for _, a := range albumCache {
-			if a.AlbumName == albumTitle {
-				albumId = a.Id
-				break
-			}
-		}
-		if albumId == "" {
-			logger.Info("Creating Immich album", "title", albumTitle)
-			newAlbum, err := a.Client.CreateAlbum(albumTitle)
-			if err == nil {
-				albumId = newAlbum.Id
-			} else {
-				logger.Error("Error creating album", "error", err)
-			}
-		}
+	if len(album.Items) == 0 {
+		logger.Info("No photos found, skipping")
+		return result
 	}
 
-	// Pre-fetch existing album assets for O(1) duplicate detection
-	existingFiles := make(map[string]string) // baseName (no extension) -> asset ID
-	if albumId != "" {
-		albumDetails, err := a.Client.GetAlbum(albumId)
-		if err == nil {
-			for _, asset := range albumDetails.Assets {
-				name := asset.OriginalFileName
-				if dot := strings.LastIndex(name, "."); dot != -1 {
-					name = name[:dot]
-				}
-				existingFiles[name] = asset.Id
-			}
-			logger.Debug("Pre-fetched album assets", "count", len(existingFiles))
+	// Resolve Immich album ID
+	albumId := findAlbumId(ac, albumTitle, albumCache)
+	if albumId == "" {
+		logger.Info("Creating Immich album", "title", albumTitle)
+		newAlbum, err := a.Client.CreateAlbum(albumTitle)
+		if err != nil {
+			// Without an album, uploads could neither be added to it nor recorded, and would repeat every run
+			logger.Error("Error creating album", "error", err)
+			result.Err = fmt.Errorf("error creating album: %w", err)
+			return result
 		}
+		albumId = newAlbum.Id
 	}
 
-	// Pre-fetch all assets uploaded by this tool globally for O(1) lookup.
-	// Avoids re-downloading and re-uploading files that exist in Immich but not in this album.
-	globalAssets, err := a.Client.SearchAssetsByDevice("immich-sync-go")
-	if err != nil {
-		logger.Warn("Failed to fetch global assets, will fall back to re-upload for duplicates", "error", err)
-		globalAssets = make(map[string]string)
-	} else {
-		logger.Debug("Pre-fetched global assets from Immich", "count", len(globalAssets))
+	lookups := &assetLookups{client: a.Client, albumId: albumId, logger: logger}
+	run := &albumSync{cfg: cfg, ac: ac, src: src, albumTitle: albumTitle, lookups: lookups, retry: retry != nil}
+	if cfg.ChecksumDedup {
+		run.checksums = newChecksumBatcher(a.Client)
+		defer run.checksums.Close()
 	}
+	defer func() {
+		if err := a.State.Save(); err != nil {
+			logger.Error("Failed to save sync state", "error", err)
+		}
+	}()
 
-	var newAssetIds []string
+	var newAssets []processResult
+	var items []report.Item
 
-	total := len(album.Photos)
+	total := len(album.Items)
 	processed := 0
 	added := 0
+	linked := 0
 	skipped := 0
 	failed := 0
 
-	numWorkers := a.Cfg.Workers
+	numWorkers := cfg.Workers
 	if numWorkers < 1 {
 		numWorkers = 1
 	}
@@ -219,10 +614,12 @@ func (a *App) processAlbum(ac config.GooglePhotosConfig, albumCache []immich.Alb
 	logger.Info("Processing items", "total_items", total, "workers", numWorkers)
 
 	// Create and start progress tracker
-	tracker := progress.New(albumTitle, total, a.Cfg.Debug)
+	tracker := progress.New(albumTitle, total, cfg.Debug)
 	tracker.Start()
+	a.setTracker(ac.URL, tracker)
+	defer a.setTracker(ac.URL, nil)
 
-	jobs := make(chan googlephotos.Photo, numWorkers*2)
+	jobs := make(chan source.Item, numWorkers*2)
 	results := make(chan processResult, numWorkers*2)
 	var wg sync.WaitGroup
 
@@ -231,15 +628,17 @@ func (a *App) processAlbum(ac config.GooglePhotosConfig, albumCache []immich.Alb
 		go func() {
 			defer wg.Done()
 			for p := range jobs {
-				id, uploaded, bytesDown, bytesUp, err := a.processItem(p, albumTitle, ac.URL, existingFiles, globalAssets)
-				results <- processResult{ID: id, WasUploaded: uploaded, Error: err, BytesDownloaded: bytesDown, BytesUploaded: bytesUp}
+				start := time.Now()
+				res := a.processItem(run, p)
+				res.URL, res.Duration = p.URL, time.Since(start)
+				results <- res
 			}
 		}()
 	}
 
 	// Feed jobs
 	go func() {
-		for _, p := range album.Photos {
+		for _, p := range album.Items {
 			jobs <- p
 		}
 		close(jobs)
@@ -251,6 +650,26 @@ func (a *App) processAlbum(ac config.GooglePhotosConfig, albumCache []immich.Alb
 		close(results)
 	}()
 
+	// flush adds a batch of new assets to the album and records them in the state store
+	flush := func(batch []processResult) error {
+		ids := make([]string, len(batch))
+		for i, res := range batch {
+			ids[i] = res.ID
+		}
+		if err := a.Client.AddAssetsToAlbum(albumId, ids); err != nil {
+			return err
+		}
+		for _, res := range batch {
+			if res.Record != nil {
+				a.State.Put(ac.URL, res.ItemID, *res.Record)
+			}
+		}
+		if err := a.State.Save(); err != nil {
+			logger.Warn("Failed to save sync state", "error", err)
+		}
+		return nil
+	}
+
 	// Stream results as they arrive, flushing new assets to album every 10%
 	flushInterval := total / 10
 	if flushInterval < 1 {
@@ -275,28 +694,33 @@ func (a *App) processAlbum(ac config.GooglePhotosConfig, albumCache []immich.Alb
 			} else if res.ID == "" {
 				skipped++
 				wasSkipped = true
+			} else {
+				linked++
 			}
 			if res.ID != "" {
-				newAssetIds = append(newAssetIds, res.ID)
+				newAssets = append(newAssets, res)
 			}
 		}
 
 		// Update progress tracker
 		tracker.RecordItem(res.BytesDownloaded, res.BytesUploaded, wasAdded, wasSkipped, wasFailed)
+		a.metrics.observeItem(ac.URL, res)
+		a.trackFailure(ac, res)
+		items = append(items, res.reportItem())
 
 		// Flush new assets to album every ~10% of total items
-		if albumId != "" && len(newAssetIds) > lastFlushCount && (processed%flushInterval == 0 || processed == total) {
-			batch := newAssetIds[lastFlushCount:]
+		if len(newAssets) > lastFlushCount && (processed%flushInterval == 0 || processed == total) {
+			batch := newAssets[lastFlushCount:]
 			logger.Info("Adding assets to album (incremental)", "count", len(batch), "progress", fmt.Sprintf("%d/%d", processed, total))
-			if err := a.Client.AddAssetsToAlbum(albumId, batch); err != nil {
+			if err := flush(batch); err != nil {
 				logger.Error("Error adding assets to album", "error", err)
 			} else {
-				lastFlushCount = len(newAssetIds)
+				lastFlushCount = len(newAssets)
 			}
 		}
 
 		// Log progress every 100 items in debug mode
-		if a.Cfg.Debug && processed%100 == 0 {
+		if cfg.Debug && processed%100 == 0 {
 			logger.Debug("Progress", "processed", processed, "total", total, "added", added, "skipped", skipped, "failed", failed)
 		}
 	}
@@ -305,55 +729,162 @@ func (a *App) processAlbum(ac config.GooglePhotosConfig, albumCache []immich.Alb
 	tracker.Stop()
 
 	// Flush any remaining assets not yet added
-	if albumId != "" && len(newAssetIds) > lastFlushCount {
-		batch := newAssetIds[lastFlushCount:]
+	if len(newAssets) > lastFlushCount {
+		batch := newAssets[lastFlushCount:]
 		logger.Info("Adding remaining assets to album", "count", len(batch), "album", albumTitle)
-		err := a.Client.AddAssetsToAlbum(albumId, batch)
-		if err != nil {
+		if err := flush(batch); err != nil {
 			logger.Error("Error adding assets to album", "error", err)
 		}
 	}
-	if a.Cfg.Debug {
+
+	// Mirror deletions only after a clean full run, so a partial scrape cannot trigger removals
+	if ac.DeletionPolicy != config.DeletionKeep && failed == 0 && retry == nil {
+		if err := a.mirrorDeletions(ac, albumId, album.Items, logger); err != nil {
+			logger.Error("Skipping deletion mirroring", "error", err)
+		}
+	}
+
+	if cfg.Debug {
 		logger.Info("Sync finished", "added", added, "skipped", skipped, "failed", failed, "total", processed)
 	}
+
+	result.Total = processed
+	result.Added = added
+	result.Linked = linked
+	result.Skipped = skipped
+	result.Failed = failed
+	result.Items = items
+	return result
 }
 
-func (a *App) processItem(p googlephotos.Photo, albumTitle, albumURL string, existingFiles map[string]string, globalAssets map[string]string) (string, bool, int64, int64, error) {
-	safeId := strings.ReplaceAll(p.ID, "/", "_")
-	safeId = strings.ReplaceAll(safeId, ":", "_")
-	baseName := fmt.Sprintf("gp_%s", safeId)
+func (a *App) processItem(run *albumSync, p source.Item) processResult {
+	albumTitle, albumURL := run.albumTitle, run.ac.URL
+	res := processResult{ItemID: p.ID}
+	now := time.Now()
+
+	// Items that failed too often wait in the dead-letter list until retried with the "retries" command
+	if !run.retry {
+		if f, ok := a.Retries.Get(albumURL, p.ID); ok && f.Dead {
+			a.Logger.Debug("Skipping dead-lettered item", "item", p.ID, "attempts", f.Attempts)
+			res.Skipped = report.SkippedDead
+			return res
+		}
+	}
+
+	// State store first: items synced in an earlier run need no Immich lookups at all.
+	// Only the restore and reupload trash policies need to know whether the user trashed them since.
+	var trashedId string
+	if rec, ok := a.State.Get(albumURL, p.ID); ok && rec.AssetID != "" {
+		switch {
+		case run.cfg.TrashPolicy == config.TrashRespect && rec.Skipped == report.SkippedTrashed:
+			a.State.Touch(albumURL, p.ID, now)
+			a.Logger.Debug("Asset is in the Immich trash (state)", "id", rec.AssetID, "item", p.ID)
+			res.Skipped = report.SkippedTrashed
+			return res
+		case run.cfg.TrashPolicy != config.TrashRespect && run.lookups.isTrashed(rec.AssetID):
+			trashedId = rec.AssetID
+		case rec.Skipped == "":
+			a.State.Touch(albumURL, p.ID, now)
+			a.Logger.Debug("Asset already synced (state)", "id", rec.AssetID, "item", p.ID)
+			return res
+		}
+		// Left in the trash under trashPolicy "respect" and restored by the user since: sync it again
+	}
+
+	baseName := itemBaseName(p.ID)
+	safeId := strings.TrimPrefix(baseName, "gp_")
+
+	existingFiles, globalAssets := run.lookups.album(), run.lookups.global()
 
 	// O(1) check against pre-fetched album assets
 	if assetId, exists := existingFiles[baseName]; exists {
 		a.Logger.Debug("Asset already in album", "id", assetId, "filename", baseName)
-		return "", false, 0, 0, nil
+		a.State.Put(albumURL, p.ID, state.Record{AssetID: assetId, TakenAt: p.TakenAt, LastSeen: now})
+		return res
 	}
 
 	// O(1) check against global Immich assets — avoids re-downloading and re-uploading
 	if assetId, exists := globalAssets[baseName]; exists {
 		a.Logger.Debug("Asset exists in Immich globally, adding to album", "id", assetId, "filename", baseName)
-		return assetId, false, 0, 0, nil
+		res.ID = assetId
+		res.Record = &state.Record{AssetID: assetId, TakenAt: p.TakenAt, LastSeen: now}
+		return res
 	}
 
-	if a.Cfg.StrictMetadata && p.TakenAt.IsZero() {
-		a.Logger.Warn("Skipping item with missing metadata date",
-			"id", p.ID, "url", p.URL)
-		return "", false, 0, 0, nil
+	// Neither in the album nor live in Immich: the user may have trashed it
+	if trashedId == "" {
+		trashedId = run.lookups.trashed()[baseName]
+	}
+	if trashedId != "" && a.applyTrashPolicy(run, p, trashedId, &res) {
+		return res
 	}
 
-	// Download original media from Google Photos
+	// Download original media from the source
 	a.Logger.Debug("Downloading item", "id", safeId)
-	r, size, ext, isVideo, err := googlephotos.DownloadMedia(a.GPClient, p.URL)
+	// Checksum dedup and embedded date recovery need the full file, so it is spooled to disk
+	media, err := run.src.Download(p, source.DownloadOptions{
+		Spool:       run.checksums != nil || p.TakenAt.IsZero(),
+		SplitMotion: run.cfg.MotionPhotos,
+	})
 	if err != nil {
-		return "", false, 0, 0, fmt.Errorf("error downloading item: %w", err)
+		res.Error = fmt.Errorf("error downloading item: %w", err)
+		res.ErrorClass = errClassDownload
+		return res
+	}
+	r, size, ext, isVideo := media.Body, media.Size, media.Ext, media.IsVideo
+	live := media.LiveVideo
+	if live != nil {
+		defer live.Body.Close()
 	}
 
-	bytesDownloaded := size
+	res.BytesDownloaded = size
+	if live != nil {
+		res.BytesDownloaded += live.Size
+	}
 
-	if isVideo && a.Cfg.SkipVideos {
+	if isVideo && run.cfg.SkipVideos {
 		r.Close()
 		a.Logger.Debug("Skipping video item", "id", p.ID)
-		return "", false, bytesDownloaded, 0, nil
+		res.Skipped = report.SkippedVideo
+		a.State.Put(albumURL, p.ID, state.Record{Skipped: res.Skipped, TakenAt: p.TakenAt, LastSeen: now})
+		return res
+	}
+
+	// Scraper had no date: fall back to the metadata embedded in the file itself
+	dateSource := resolveTakenAt(&p, r, size)
+	a.Logger.Debug("Resolved capture date", "id", safeId, "date_source", dateSource, "taken_at", p.TakenAt)
+
+	if run.cfg.StrictMetadata && p.TakenAt.IsZero() {
+		r.Close()
+		a.Logger.Warn("Skipping item with missing metadata date",
+			"id", p.ID, "url", p.URL)
+		res.Skipped = report.SkippedNoDate
+		a.State.Put(albumURL, p.ID, state.Record{Skipped: res.Skipped, LastSeen: now})
+		return res
+	}
+	if dateSource != "scraper" && !p.TakenAt.IsZero() {
+		a.Logger.Info("Recovered capture date from embedded metadata", "id", safeId, "date_source", dateSource, "taken_at", p.TakenAt.Format(time.RFC3339))
+	}
+
+	// Exact-content match already in Immich (e.g. from a phone backup): link instead of uploading
+	if run.checksums != nil && media.Checksum != "" {
+		check, err := run.checksums.Check(media.Checksum)
+		if err != nil {
+			a.Logger.Warn("Bulk upload check failed, uploading anyway", "id", safeId, "error", err)
+		} else if check.Action == "reject" && check.AssetId != "" && check.IsTrashed {
+			// Same content in the trash: a new upload would be deduplicated against it, unless
+			// the reupload policy deleted it
+			if a.applyTrashPolicy(run, p, check.AssetId, &res) {
+				r.Close()
+				return res
+			}
+		} else if check.Action == "reject" && check.AssetId != "" {
+			r.Close()
+			a.Logger.Debug("Asset content already in Immich, adding to album", "id", check.AssetId, "filename", baseName)
+			res.ID = check.AssetId
+			res.Record = &state.Record{AssetID: check.AssetId, Checksum: media.Checksum, Size: size, TakenAt: p.TakenAt, LastSeen: now}
+			return res
+		}
 	}
 
 	filename := baseName + ext
@@ -371,23 +902,148 @@ func (a *App) processItem(p googlephotos.Photo, albumTitle, albumURL string, exi
 			"id", safeId, "url", p.URL, "is_video", isVideo)
 	}
 
-	uploadedId, isDup, err := a.Client.UploadAssetStream(r, filename, size, p.TakenAt, description)
+	// Motion photo: upload the video part first so the still can be linked to it
+	var liveVideoId string
+	if live != nil {
+		liveVideoId, _, err = a.Client.UploadAsset(immich.AssetUpload{
+			Reader:    live.Body,
+			Filename:  baseName + "_live" + live.Ext,
+			Size:      live.Size,
+			CreatedAt: p.TakenAt,
+			Checksum:  live.Checksum,
+		})
+		if err != nil {
+			a.Logger.Warn("Failed to upload motion photo video, importing still only", "id", safeId, "error", err)
+			liveVideoId = ""
+		} else {
+			res.BytesUploaded += live.Size
+		}
+	}
+
+	upload := immich.AssetUpload{
+		Reader:      r,
+		Filename:    filename,
+		Size:        size,
+		CreatedAt:   p.TakenAt,
+		Description: description,
+		Checksum:    media.Checksum,
+	}
+	a.applyMetadata(&upload, run.cfg.WriteMetadata, p, ext, isVideo, albumURL)
+	size = upload.Size
+
+	hasher := sha1.New()
+	upload.Reader = io.TeeReader(upload.Reader, hasher)
+	uploadedId, isDup, err := a.Client.UploadAsset(upload)
 	r.Close()
 	if err != nil {
-		return "", false, bytesDownloaded, 0, fmt.Errorf("error uploading %s: %w", filename, err)
+		res.Error = fmt.Errorf("error uploading %s: %w", filename, err)
+		res.ErrorClass = errClassUpload
+		return res
 	}
 	if uploadedId == "" {
-		return "", false, bytesDownloaded, 0, fmt.Errorf("upload returned empty ID for %s", filename)
+		res.Error = fmt.Errorf("upload returned empty ID for %s", filename)
+		res.ErrorClass = errClassUpload
+		return res
 	}
 
-	bytesUploaded := size
+	checksum := upload.Checksum
+	if checksum == "" {
+		checksum = hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	if liveVideoId != "" {
+		if err := a.Client.SetLivePhotoVideo(uploadedId, liveVideoId); err != nil {
+			a.Logger.Warn("Failed to link motion photo video", "id", uploadedId, "video_id", liveVideoId, "error", err)
+		}
+	}
+
+	res.ID = uploadedId
+	res.BytesUploaded += size
+	res.Record = &state.Record{
+		AssetID:  uploadedId,
+		Checksum: checksum,
+		Size:     size,
+		TakenAt:  p.TakenAt,
+		LastSeen: now,
+	}
 
 	if isDup {
 		a.Logger.Debug("Asset deduplicated by Immich", "filename", filename, "id", uploadedId)
-		return uploadedId, false, bytesDownloaded, bytesUploaded, nil
+		if run.lookups.isTrashed(uploadedId) {
+			// Immich matched the content to a trashed asset, so no fresh copy could be uploaded
+			res.ID, res.Record = "", nil
+			if run.cfg.TrashPolicy != config.TrashReupload {
+				a.applyTrashPolicy(run, p, uploadedId, &res)
+			} else {
+				// The trashed copy was not known before the upload, e.g. the trash lookup failed
+				a.Logger.Info("Immich deduplicated the new copy against a trashed asset, restoring it instead", "id", uploadedId, "item", p.ID)
+				a.restoreTrashed(run, p, uploadedId, &res)
+			}
+		}
+		return res
 	}
 
 	a.Logger.Debug("Uploaded item", "filename", filename, "id", uploadedId)
-	return uploadedId, true, bytesDownloaded, bytesUploaded, nil
+	res.WasUploaded = true
+	res.Record.Uploaded = true
+	return res
 }
 
+// resolveTakenAt fills in a missing capture date from the metadata embedded in the downloaded file
+// and returns where the date came from ("scraper", "embedded-<kind>" or "none").
+func resolveTakenAt(p *source.Item, r io.Reader, size int64) string {
+	if !p.TakenAt.IsZero() {
+		return "scraper"
+	}
+	if ra, ok := r.(io.ReaderAt); ok {
+		if t, kind, found := metadata.ReadDate(ra, size); found {
+			p.TakenAt = t
+			return "embedded-" + kind
+		}
+	}
+	return "none"
+}
+
+// applyTrashPolicy handles an item whose asset the user moved to the Immich trash. It returns
+// false when the item should be uploaded again (trashPolicy "reupload").
+func (a *App) applyTrashPolicy(run *albumSync, p source.Item, assetId string, res *processResult) bool {
+	switch run.cfg.TrashPolicy {
+	case config.TrashRestore:
+		a.restoreTrashed(run, p, assetId, res)
+		return true
+	case config.TrashReupload:
+		if !run.lookups.isTrashed(assetId) {
+			// Not uploaded by this tool, e.g. a phone backup found by checksum dedup: never delete it
+			a.Logger.Info("Trashed asset was not uploaded by this tool, restoring it instead of uploading a new copy", "id", assetId, "item", p.ID)
+			a.restoreTrashed(run, p, assetId, res)
+			return true
+		}
+		// Immich deduplicates uploads against the trash, so a fresh copy needs the old one gone
+		if err := a.Client.DeleteAssets([]string{assetId}); err != nil {
+			res.Error = fmt.Errorf("error deleting trashed asset %s: %w", assetId, err)
+			res.ErrorClass = errClassRestore
+			return true
+		}
+		a.State.Delete(run.ac.URL, p.ID)
+		a.Logger.Info("Deleted trashed asset, uploading a new copy", "id", assetId, "item", p.ID)
+		return false
+	default:
+		// Remember the item so later runs skip it without looking at the trash again
+		a.Logger.Debug("Asset is in the Immich trash, leaving it there", "id", assetId, "item", p.ID)
+		a.State.Put(run.ac.URL, p.ID, state.Record{AssetID: assetId, TakenAt: p.TakenAt, LastSeen: time.Now(), Skipped: report.SkippedTrashed})
+		res.Skipped = report.SkippedTrashed
+		return true
+	}
+}
+
+// restoreTrashed takes an asset out of the Immich trash and queues it to be re-added to the album
+func (a *App) restoreTrashed(run *albumSync, p source.Item, assetId string, res *processResult) {
+	if err := a.Client.RestoreAssets([]string{assetId}); err != nil {
+		res.Error = fmt.Errorf("error restoring trashed asset %s: %w", assetId, err)
+		res.ErrorClass = errClassRestore
+		return
+	}
+	a.Logger.Info("Restored asset from the Immich trash", "id", assetId, "item", p.ID, "album", run.albumTitle)
+	res.ID, res.Restored = assetId, true
+	res.Record = &state.Record{AssetID: assetId, TakenAt: p.TakenAt, LastSeen: time.Now()}
+}
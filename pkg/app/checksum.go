@@ -0,0 +1,90 @@
+package app
+
+import (
+	"strconv"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/immich"
+)
+
+const (
+	checksumBatchSize = 50
+	checksumBatchWait = 200 * time.Millisecond
+)
+
+type checksumRequest struct {
+	checksum string
+	reply    chan checksumReply
+}
+
+type checksumReply struct {
+	result immich.BulkCheckResult
+	err    error
+}
+
+// checksumBatcher coalesces checksum lookups from concurrent workers into Immich bulk upload checks
+type checksumBatcher struct {
+	client ImmichAPI
+	reqs   chan checksumRequest
+}
+
+func newChecksumBatcher(client ImmichAPI) *checksumBatcher {
+	b := &checksumBatcher{
+		client: client,
+		reqs:   make(chan checksumRequest),
+	}
+	go b.loop()
+	return b
+}
+
+// Check returns Immich's verdict for a hex SHA-1 checksum, waiting for the batch it lands in
+func (b *checksumBatcher) Check(checksum string) (immich.BulkCheckResult, error) {
+	reply := make(chan checksumReply, 1)
+	b.reqs <- checksumRequest{checksum: checksum, reply: reply}
+	r := <-reply
+	return r.result, r.err
+}
+
+// Close flushes pending lookups and stops the batcher
+func (b *checksumBatcher) Close() {
+	close(b.reqs)
+}
+
+func (b *checksumBatcher) loop() {
+	var pending []checksumRequest
+	var timeout <-chan time.Time
+
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		checksums := make(map[string]string, len(pending))
+		for i, req := range pending {
+			checksums[strconv.Itoa(i)] = req.checksum
+		}
+		results, err := b.client.BulkUploadCheck(checksums)
+		for i, req := range pending {
+			req.reply <- checksumReply{result: results[strconv.Itoa(i)], err: err}
+		}
+		pending = nil
+		timeout = nil
+	}
+
+	for {
+		select {
+		case req, ok := <-b.reqs:
+			if !ok {
+				flush()
+				return
+			}
+			pending = append(pending, req)
+			if len(pending) >= checksumBatchSize {
+				flush()
+			} else if timeout == nil {
+				timeout = time.After(checksumBatchWait)
+			}
+		case <-timeout:
+			flush()
+		}
+	}
+}
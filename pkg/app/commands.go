@@ -0,0 +1,190 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/immich"
+	"warreth.dev/immich-sync/pkg/state"
+)
+
+// AlbumResult pairs a configured album with the outcome of its sync
+type AlbumResult struct {
+	Album  config.AlbumConfig
+	Result SyncResult
+}
+
+// RunOnce syncs every configured album a single time, honouring albumWorkers, and records
+// the results like the daemon does. Results are in config order.
+func (a *App) RunOnce() ([]AlbumResult, error) {
+	if err := a.Connect(); err != nil {
+		return nil, err
+	}
+
+	cfg := a.config()
+	albums := cfg.AllAlbums()
+	albumWorkers := cfg.AlbumWorkers
+	if albumWorkers < 1 {
+		albumWorkers = 1
+	}
+
+	results := make([]AlbumResult, len(albums))
+	sem := make(chan struct{}, albumWorkers)
+	var wg sync.WaitGroup
+	for i, ac := range albums {
+		wg.Add(1)
+		go func(i int, ac config.AlbumConfig) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			started := time.Now()
+			result := a.SyncAlbum(ac)
+			a.recordRun(ac, started, result, nextRun(ac, time.Now()))
+			results[i] = AlbumResult{Album: ac, Result: result}
+		}(i, ac)
+	}
+	wg.Wait()
+	a.notifier.Wait()
+	return results, nil
+}
+
+// ImmichAlbums lists all albums in Immich
+func (a *App) ImmichAlbums() ([]immich.Album, error) {
+	return a.Client.GetAlbums()
+}
+
+// AlbumStatus is the persisted state of a configured album
+type AlbumStatus struct {
+	Album  config.AlbumConfig
+	Run    state.AlbumRun
+	HasRun bool
+}
+
+// Status returns the last recorded run of every configured album
+func (a *App) Status() []AlbumStatus {
+	albums := a.config().AllAlbums()
+	out := make([]AlbumStatus, 0, len(albums))
+	for _, ac := range albums {
+		run, ok := a.Runs.Get(ac.URL)
+		out = append(out, AlbumStatus{Album: ac, Run: run, HasRun: ok})
+	}
+	return out
+}
+
+// VerifyResult compares a source album with its Immich counterpart
+type VerifyResult struct {
+	Album       config.AlbumConfig
+	Title       string
+	AlbumId     string
+	SourceCount int // items in the source album
+	ImmichCount int // assets of synced items in the Immich album
+	Missing     int // source items not in the Immich album
+	Skipped     int // source items deliberately not synced, e.g. videos with skipVideos or dead letters
+	Extra       int // assets of synced items no longer in the source album
+	Err         error
+}
+
+// OK reports whether both sides match
+func (v VerifyResult) OK() bool {
+	return v.Err == nil && v.Missing == 0 && v.Extra == 0
+}
+
+// Verify scrapes every configured album and compares it with the Immich album, read-only
+func (a *App) Verify() ([]VerifyResult, error) {
+	if err := a.Connect(); err != nil {
+		return nil, err
+	}
+	albumCache, err := a.Client.GetAlbums()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Immich album list: %w", err)
+	}
+
+	var results []VerifyResult
+	for _, ac := range a.config().AllAlbums() {
+		results = append(results, a.verifyAlbum(ac, albumCache))
+	}
+	return results, nil
+}
+
+func (a *App) verifyAlbum(ac config.AlbumConfig, albumCache []immich.Album) VerifyResult {
+	res := VerifyResult{Album: ac}
+	src, ok := a.Sources[ac.Source]
+	if !ok {
+		res.Err = fmt.Errorf("unknown source %q", ac.Source)
+		return res
+	}
+	album, err := src.FetchAlbum(ac.URL)
+	if err != nil {
+		res.Err = fmt.Errorf("error scraping album: %w", err)
+		return res
+	}
+	res.Title = album.Title
+	if ac.AlbumName != "" {
+		res.Title = ac.AlbumName
+	}
+	res.SourceCount = len(album.Items)
+
+	res.AlbumId = findAlbumId(ac, res.Title, albumCache)
+	inAlbum := make(map[string]bool)
+	byName := make(map[string]string) // file name stem -> asset ID
+	if res.AlbumId != "" {
+		details, err := a.Client.GetAlbum(res.AlbumId)
+		if err != nil {
+			res.Err = fmt.Errorf("error fetching Immich album: %w", err)
+			return res
+		}
+		for _, asset := range details.Assets {
+			inAlbum[asset.Id] = true
+			name := asset.OriginalFileName
+			if dot := strings.LastIndex(name, "."); dot != -1 {
+				name = name[:dot]
+			}
+			byName[name] = asset.Id
+		}
+	}
+
+	// The state store maps every synced item to its asset, whatever the asset's file name:
+	// uploads as well as assets linked by name or content
+	records := a.State.Items(ac.URL)
+	synced := make(map[string]bool) // asset IDs of synced items in the Immich album
+	for _, rec := range records {
+		if inAlbum[rec.AssetID] {
+			synced[rec.AssetID] = true
+		}
+	}
+
+	current := make(map[string]bool, len(album.Items)) // asset IDs of items still in the source
+	for _, p := range album.Items {
+		rec, ok := records[p.ID]
+		switch {
+		case ok && inAlbum[rec.AssetID]:
+			current[rec.AssetID] = true
+		case ok && rec.Skipped != "":
+			res.Skipped++
+		case ok && rec.AssetID != "":
+			res.Missing++
+		default:
+			if f, queued := a.Retries.Get(ac.URL, p.ID); queued && f.Dead {
+				res.Skipped++
+				continue
+			}
+			// No record, e.g. a new or lost state directory: fall back to the uploaded file name
+			if assetId, found := byName[itemBaseName(p.ID)]; found {
+				current[assetId] = true
+				synced[assetId] = true
+				continue
+			}
+			res.Missing++
+		}
+	}
+	res.ImmichCount = len(synced)
+	for assetId := range synced {
+		if !current[assetId] {
+			res.Extra++
+		}
+	}
+	return res
+}
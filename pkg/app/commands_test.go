@@ -0,0 +1,87 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/immich/immichtest"
+)
+
+func (e *testEnv) verify() VerifyResult {
+	e.t.Helper()
+	results, err := e.app.Verify()
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if len(results) != 1 {
+		e.t.Fatalf("got %d verify results", len(results))
+	}
+	if results[0].Err != nil {
+		e.t.Fatal(results[0].Err)
+	}
+	return results[0]
+}
+
+func TestVerify(t *testing.T) {
+	e := newTestEnv(t, func(c *config.Config) { c.ChecksumDedup = true })
+	e.src.add(3)
+	// item001 is linked to an asset backed up from a phone, so its file name has no "gp_" prefix
+	e.immich.AddAsset(immichtest.Asset{OriginalFileName: "IMG_0001.jpg", Checksum: sha1Hex([]byte("jpeg-item001"))})
+	e.sync()
+
+	if v := e.verify(); !v.OK() || v.SourceCount != 3 || v.ImmichCount != 3 || v.AlbumId != e.album().Id {
+		t.Fatalf("after sync: %+v", v)
+	}
+
+	// A new source item is missing until the next sync
+	e.src.add(1)
+	if v := e.verify(); v.Missing != 1 || v.Extra != 0 || v.ImmichCount != 3 {
+		t.Errorf("new item: %+v", v)
+	}
+
+	// Removed source items leave extra assets behind with the default deletion policy
+	e.sync()
+	e.src.remove("item001")
+	if v := e.verify(); v.Missing != 0 || v.Extra != 1 || v.SourceCount != 3 || v.ImmichCount != 4 {
+		t.Errorf("removed item: %+v", v)
+	}
+
+	// Assets added to the album by hand are not synced items
+	id := e.immich.AddAsset(immichtest.Asset{OriginalFileName: "gp_manual.jpg"})
+	if err := e.app.Client.AddAssetsToAlbum(e.album().Id, []string{id}); err != nil {
+		t.Fatal(err)
+	}
+	if v := e.verify(); v.Extra != 1 || v.ImmichCount != 4 {
+		t.Errorf("manual asset: %+v", v)
+	}
+}
+
+func TestVerifyWithoutState(t *testing.T) {
+	e := newTestEnv(t, nil)
+	e.src.add(3)
+	e.sync()
+
+	// A lost state directory: uploads are still found by their file name
+	for _, id := range []string{"item000", "item001", "item002"} {
+		e.app.State.Delete(testAlbumURL, id)
+	}
+	if v := e.verify(); !v.OK() || v.ImmichCount != 3 {
+		t.Errorf("without state: %+v", v)
+	}
+}
+
+func TestVerifySkipped(t *testing.T) {
+	e := newTestEnv(t, func(c *config.Config) {
+		c.StrictMetadata = true
+		c.RetryAttempts = 1
+	})
+	e.src.add(3)
+	e.src.items[0].TakenAt = time.Time{} // no date: skipped by strictMetadata
+	e.src.setFail("item001", true)       // dead-lettered after one failure
+	e.sync()
+
+	if v := e.verify(); !v.OK() || v.Skipped != 2 || v.Missing != 0 || v.ImmichCount != 1 {
+		t.Errorf("skipped items: %+v", v)
+	}
+}
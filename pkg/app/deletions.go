@@ -0,0 +1,118 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/source"
+)
+
+// itemBaseName returns the Immich file name stem (no extension) used for a source item
+func itemBaseName(itemID string) string {
+	safeId := strings.ReplaceAll(itemID, "/", "_")
+	safeId = strings.ReplaceAll(safeId, ":", "_")
+	return fmt.Sprintf("gp_%s", safeId)
+}
+
+// mirrorDeletions applies the album's deletion policy to the assets of synced items, as recorded
+// in the state store, that are in the Immich album but no longer in the scraped source album.
+// Assets the sync did not add, e.g. uploaded by hand, are never touched, and only assets this
+// tool uploaded are ever trashed: linked and restored assets, like phone backups found by
+// checksum dedup, belong to the user and are only removed from the album.
+func (a *App) mirrorDeletions(ac config.AlbumConfig, albumId string, items []source.Item, logger *slog.Logger) error {
+	if ac.DeletionPolicy == config.DeletionKeep || albumId == "" {
+		return nil
+	}
+
+	details, err := a.Client.GetAlbum(albumId)
+	if err != nil {
+		return fmt.Errorf("error fetching Immich album: %w", err)
+	}
+	inAlbum := make(map[string]bool, len(details.Assets))
+	for _, asset := range details.Assets {
+		inAlbum[asset.Id] = true
+	}
+
+	scraped := make(map[string]bool, len(items))
+	for _, p := range items {
+		scraped[p.ID] = true
+	}
+
+	// Items can share an asset (same content), which stays as long as one of them is scraped
+	records := a.State.Items(ac.URL)
+	managed := make(map[string]bool)  // asset IDs of synced items in the album
+	kept := make(map[string]bool)     // asset IDs of items still in the source album
+	uploaded := make(map[string]bool) // asset IDs this tool uploaded
+	var gone []string                 // item IDs no longer in the source album
+	for itemID, rec := range records {
+		if inAlbum[rec.AssetID] {
+			managed[rec.AssetID] = true
+		}
+		if rec.Uploaded {
+			uploaded[rec.AssetID] = true
+		}
+		if scraped[itemID] {
+			kept[rec.AssetID] = true
+		} else {
+			gone = append(gone, itemID)
+		}
+	}
+	if len(gone) == 0 {
+		return nil
+	}
+	var ids []string
+	for assetId := range managed {
+		if !kept[assetId] {
+			ids = append(ids, assetId)
+		}
+	}
+
+	if len(ids) > 0 {
+		// Safety net against broken scrapes: never remove an abnormally large part of the album
+		fraction := float64(len(ids)) / float64(len(managed))
+		if threshold := ac.MaxDeletionFraction(); fraction > threshold {
+			return fmt.Errorf("refusing to remove %d of %d album items (%.0f%% exceeds deletion threshold of %.0f%%)",
+				len(ids), len(managed), fraction*100, threshold*100)
+		}
+
+		logger.Info("Removing items deleted from source album", "count", len(ids), "policy", ac.DeletionPolicy)
+		if err := a.Client.RemoveAssetsFromAlbum(albumId, ids); err != nil {
+			return fmt.Errorf("error removing assets from album: %w", err)
+		}
+	}
+
+	// Forget removed items so they are re-added if they ever reappear in the source album
+	for _, itemID := range gone {
+		a.State.Delete(ac.URL, itemID)
+	}
+
+	if ac.DeletionPolicy != config.DeletionTrash {
+		return nil
+	}
+
+	// Only trash uploaded assets that are not referenced by any other album
+	var orphans []string
+	for _, assetId := range ids {
+		if !uploaded[assetId] {
+			logger.Debug("Asset was not uploaded by this sync, keeping it", "id", assetId)
+			continue
+		}
+		albums, err := a.Client.GetAlbumsForAsset(assetId)
+		if err != nil {
+			logger.Warn("Failed to look up albums for asset, keeping it", "id", assetId, "error", err)
+			continue
+		}
+		if len(albums) == 0 {
+			orphans = append(orphans, assetId)
+		}
+	}
+	if len(orphans) > 0 {
+		logger.Info("Trashing assets no longer referenced by any album", "count", len(orphans))
+		if err := a.Client.TrashAssets(orphans); err != nil {
+			return fmt.Errorf("error trashing assets: %w", err)
+		}
+	}
+	return nil
+}
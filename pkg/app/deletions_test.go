@@ -0,0 +1,135 @@
+package app
+
+import (
+	"slices"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/immich/immichtest"
+)
+
+// newDeletionEnv syncs 8 items with the given deletion policy. item001 is linked to an asset
+// backed up from a phone, and the album also holds an asset added by hand.
+func newDeletionEnv(t *testing.T, policy string) (e *testEnv, phoneId, manualId string) {
+	e = newTestEnv(t, func(c *config.Config) {
+		c.ChecksumDedup = true
+		c.Workers = 8 // fills checksum batches instead of waiting for them
+		c.Albums[0].DeletionPolicy = policy
+	})
+	e.src.add(8)
+	phoneId = e.immich.AddAsset(immichtest.Asset{OriginalFileName: "IMG_0001.jpg", Checksum: sha1Hex([]byte("jpeg-item001"))})
+	manualId = e.immich.AddAsset(immichtest.Asset{OriginalFileName: "gp_manual.jpg"})
+	e.immich.AddAlbum("Holiday", manualId)
+	e.sync()
+	if got := len(e.album().AssetIds); got != 9 {
+		t.Fatalf("album has %d assets, want 9", got)
+	}
+	return e, phoneId, manualId
+}
+
+func TestMirrorDeletionsRemove(t *testing.T) {
+	e, phoneId, manualId := newDeletionEnv(t, config.DeletionRemove)
+	removedId := e.asset("item002").Id
+	e.src.remove("item001")
+	e.src.remove("item002")
+	e.sync()
+
+	album := e.album().AssetIds
+	if len(album) != 7 || slices.Contains(album, phoneId) || slices.Contains(album, removedId) {
+		t.Errorf("album assets %v, want item001 and item002 removed", album)
+	}
+	if !slices.Contains(album, manualId) {
+		t.Error("asset added by hand was removed")
+	}
+	for _, id := range []string{"item001", "item002"} {
+		if _, ok := e.app.State.Get(testAlbumURL, id); ok {
+			t.Errorf("%s still in the sync state", id)
+		}
+	}
+	for _, a := range e.immich.Assets() {
+		if a.IsTrashed {
+			t.Errorf("asset %s trashed with policy remove", a.Id)
+		}
+	}
+}
+
+func TestMirrorDeletionsTrash(t *testing.T) {
+	e, phoneId, _ := newDeletionEnv(t, config.DeletionTrash)
+	removedId := e.asset("item002").Id
+	// The phone asset is in no other album, but belongs to the user and must not be trashed
+	e.src.remove("item001")
+	e.src.remove("item002")
+	e.sync()
+
+	for _, a := range e.immich.Assets() {
+		if want := a.Id == removedId; a.IsTrashed != want {
+			t.Errorf("asset %s (%s): trashed %v, want %v", a.Id, a.OriginalFileName, a.IsTrashed, want)
+		}
+	}
+	if slices.Contains(e.album().AssetIds, phoneId) {
+		t.Error("phone asset not removed from the album")
+	}
+
+	// Uploaded assets that another album still references are only removed from this one
+	sharedId := e.asset("item003").Id
+	e.immich.AddAlbum("Other", sharedId)
+	e.src.remove("item003")
+	e.sync()
+	for _, a := range e.immich.Assets() {
+		if a.Id == sharedId && a.IsTrashed {
+			t.Error("asset of another album trashed")
+		}
+	}
+	for _, al := range e.immich.Albums() {
+		if al.AlbumName == "Holiday" && slices.Contains(al.AssetIds, sharedId) {
+			t.Error("shared asset not removed from the album")
+		}
+	}
+}
+
+func TestMirrorDeletionsSharedAsset(t *testing.T) {
+	e, _, _ := newDeletionEnv(t, config.DeletionRemove)
+	// item008 has the same content as item003 and shares its asset
+	e.src.add(1)
+	e.src.setData("item008", []byte("jpeg-item003"))
+	e.sync()
+	shared := e.asset("item003").Id
+	if e.asset("item008").Id != shared {
+		t.Fatal("item008 does not share the asset of item003")
+	}
+
+	e.src.remove("item003")
+	e.sync()
+	if !slices.Contains(e.album().AssetIds, shared) {
+		t.Error("asset removed although item008 still uses it")
+	}
+	if _, ok := e.app.State.Get(testAlbumURL, "item003"); ok {
+		t.Error("item003 still in the sync state")
+	}
+}
+
+func TestMirrorDeletionsThreshold(t *testing.T) {
+	e, _, _ := newDeletionEnv(t, config.DeletionRemove)
+	// 3 of 8 synced assets exceeds the default threshold of 25%
+	for _, id := range []string{"item000", "item001", "item002"} {
+		e.src.remove(id)
+	}
+	e.sync()
+	if got := len(e.album().AssetIds); got != 9 {
+		t.Errorf("album has %d assets, want all 9 kept", got)
+	}
+	if _, ok := e.app.State.Get(testAlbumURL, "item000"); !ok {
+		t.Error("item000 forgot although nothing was removed")
+	}
+}
+
+func TestMirrorDeletionsThresholdZero(t *testing.T) {
+	e, _, _ := newDeletionEnv(t, config.DeletionRemove)
+	zero := 0.0
+	e.app.Cfg.Albums[0].DeletionThreshold = &zero
+	e.src.remove("item001")
+	e.sync()
+	if got := len(e.album().AssetIds); got != 9 {
+		t.Errorf("album has %d assets, want all 9 kept with a threshold of 0", got)
+	}
+}
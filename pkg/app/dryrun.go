@@ -0,0 +1,234 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/immich"
+	"warreth.dev/immich-sync/pkg/report"
+	"warreth.dev/immich-sync/pkg/source"
+)
+
+// Planned item actions of a dry run
+const (
+	PlanAlreadyInAlbum = "already-in-album" // nothing to do
+	PlanLinkExisting   = "link-existing"    // asset exists in Immich and would be added to the album
+	PlanUploadNew      = "upload-new"       // item would be downloaded and uploaded
+	PlanSkipStrict     = "skip-strict"      // no capture date and strictMetadata is enabled
+	PlanSkipVideo      = "skip-video"       // video and skipVideos is enabled
+	PlanSkipTrashed    = "skip-trashed"     // asset is in the Immich trash and trashPolicy is "respect"
+	PlanRestoreTrashed = "restore-trashed"  // asset would be restored from the trash and re-added
+	PlanSkipDead       = "skip-dead-letter" // failed too often, see the "retries" command
+	PlanError          = "error"            // item could not be inspected
+)
+
+// PlannedItem is the action a sync would take for a single item
+type PlannedItem struct {
+	ItemID string
+	URL    string
+	Action string
+	Reason string
+}
+
+// AlbumPlan is the outcome of a dry run for one album
+type AlbumPlan struct {
+	Album       config.AlbumConfig
+	Title       string
+	AlbumId     string // empty if the album does not exist yet
+	CreateAlbum bool   // a sync would create the Immich album
+	Items       []PlannedItem
+	Err         error
+}
+
+// Counts returns the number of items per planned action
+func (p AlbumPlan) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, item := range p.Items {
+		counts[item.Action]++
+	}
+	return counts
+}
+
+// DryRun plans a sync of every configured album without changing Immich or the sync state.
+// Only read-only Immich endpoints are used. With download, new items are downloaded to detect
+// videos, embedded dates and (with checksumDedup) content already in Immich; without it these
+// are judged from the source metadata alone.
+func (a *App) DryRun(download bool) ([]AlbumPlan, error) {
+	if err := a.Connect(); err != nil {
+		return nil, err
+	}
+	albumCache, err := a.Client.GetAlbums()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Immich album list: %w", err)
+	}
+
+	var plans []AlbumPlan
+	for _, ac := range a.config().AllAlbums() {
+		plans = append(plans, a.planAlbum(ac, albumCache, download))
+	}
+	return plans, nil
+}
+
+func (a *App) planAlbum(ac config.AlbumConfig, albumCache []immich.Album, download bool) AlbumPlan {
+	logger := a.Logger.With("album_url", ac.URL)
+	plan := AlbumPlan{Album: ac}
+
+	src, ok := a.Sources[ac.Source]
+	if !ok {
+		plan.Err = fmt.Errorf("unknown source %q", ac.Source)
+		return plan
+	}
+	album, err := src.FetchAlbum(ac.URL)
+	if err != nil {
+		plan.Err = fmt.Errorf("error scraping album: %w", err)
+		return plan
+	}
+
+	plan.Title = album.Title
+	if ac.AlbumName != "" {
+		plan.Title = ac.AlbumName
+	}
+	if len(album.Items) == 0 {
+		return plan
+	}
+
+	plan.AlbumId = findAlbumId(ac, plan.Title, albumCache)
+	plan.CreateAlbum = plan.AlbumId == ""
+
+	cfg := a.config()
+	run := &albumSync{
+		cfg:        cfg,
+		ac:         ac,
+		src:        src,
+		albumTitle: plan.Title,
+		lookups:    &assetLookups{client: a.Client, albumId: plan.AlbumId, logger: logger},
+	}
+	if download && cfg.ChecksumDedup {
+		run.checksums = newChecksumBatcher(a.Client)
+		defer run.checksums.Close()
+	}
+
+	numWorkers := 1
+	if download && cfg.Workers > 1 {
+		numWorkers = cfg.Workers
+	}
+
+	plan.Items = make([]PlannedItem, len(album.Items))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				plan.Items[i] = a.planItem(run, album.Items[i], download)
+			}
+		}()
+	}
+	for i := range album.Items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return plan
+}
+
+// planItem mirrors the decisions of processItem without any side effects
+func (a *App) planItem(run *albumSync, p source.Item, download bool) PlannedItem {
+	planned := PlannedItem{ItemID: p.ID, URL: p.URL}
+
+	if f, ok := a.Retries.Get(run.ac.URL, p.ID); ok && f.Dead {
+		planned.Action, planned.Reason = PlanSkipDead, fmt.Sprintf("failed %d times: %s", f.Attempts, f.Error)
+		return planned
+	}
+
+	var trashedId string
+	if rec, ok := a.State.Get(run.ac.URL, p.ID); ok && rec.AssetID != "" {
+		switch {
+		case run.cfg.TrashPolicy == config.TrashRespect && rec.Skipped == report.SkippedTrashed:
+			planned.Action, planned.Reason = PlanSkipTrashed, "in trash (sync state)"
+			return planned
+		case run.cfg.TrashPolicy != config.TrashRespect && run.lookups.isTrashed(rec.AssetID):
+			trashedId = rec.AssetID
+		case rec.Skipped == "":
+			planned.Action, planned.Reason = PlanAlreadyInAlbum, "sync state"
+			return planned
+		}
+	}
+
+	baseName := itemBaseName(p.ID)
+	if _, exists := run.lookups.album()[baseName]; exists {
+		planned.Action, planned.Reason = PlanAlreadyInAlbum, "album assets"
+		return planned
+	}
+	if _, exists := run.lookups.global()[baseName]; exists {
+		planned.Action, planned.Reason = PlanLinkExisting, "uploaded earlier"
+		return planned
+	}
+	if trashedId == "" {
+		trashedId = run.lookups.trashed()[baseName]
+	}
+	if trashedId != "" {
+		switch run.cfg.TrashPolicy {
+		case config.TrashRestore:
+			planned.Action, planned.Reason = PlanRestoreTrashed, "in trash"
+			return planned
+		case config.TrashReupload:
+			planned.Reason = "in trash, would be deleted for a new copy"
+		default:
+			planned.Action, planned.Reason = PlanSkipTrashed, "in trash"
+			return planned
+		}
+	}
+
+	if !download {
+		planned.Action = PlanUploadNew
+		if run.cfg.StrictMetadata && p.TakenAt.IsZero() {
+			planned.Action, planned.Reason = PlanSkipStrict, "no date from source, embedded date not checked"
+		}
+		return planned
+	}
+
+	media, err := run.src.Download(p, source.DownloadOptions{Spool: true})
+	if err != nil {
+		planned.Action, planned.Reason = PlanError, err.Error()
+		return planned
+	}
+	defer media.Body.Close()
+
+	if media.IsVideo && run.cfg.SkipVideos {
+		planned.Action = PlanSkipVideo
+		return planned
+	}
+	dateSource := resolveTakenAt(&p, media.Body, media.Size)
+	if run.cfg.StrictMetadata && p.TakenAt.IsZero() {
+		planned.Action, planned.Reason = PlanSkipStrict, "no capture date"
+		return planned
+	}
+
+	if run.checksums != nil && media.Checksum != "" {
+		check, err := run.checksums.Check(media.Checksum)
+		if err == nil && check.Action == "reject" && check.AssetId != "" {
+			planned.Action, planned.Reason = PlanLinkExisting, "same content"
+			if check.IsTrashed {
+				planned.Action, planned.Reason = PlanRestoreTrashed, "same content in trash"
+				switch {
+				case run.cfg.TrashPolicy == config.TrashRespect:
+					planned.Action = PlanSkipTrashed
+				case run.cfg.TrashPolicy == config.TrashReupload && run.lookups.isTrashed(check.AssetId):
+					planned.Action, planned.Reason = PlanUploadNew, "same content in trash, would be deleted for a new copy"
+				}
+			}
+			return planned
+		}
+	}
+
+	reason := "date: " + dateSource
+	if planned.Reason != "" {
+		reason = planned.Reason + ", " + reason
+	}
+	planned.Action, planned.Reason = PlanUploadNew, reason
+	return planned
+}
@@ -0,0 +1,25 @@
+package app
+
+import "warreth.dev/immich-sync/pkg/immich"
+
+// ImmichAPI is the part of the Immich client used by App.
+// *immich.Client implements it; tests can substitute a fake or point the client at immichtest.
+type ImmichAPI interface {
+	GetUser() (string, string, error)
+	GetAlbums() ([]immich.Album, error)
+	GetAlbum(albumId string) (*immich.Album, error)
+	CreateAlbum(name string) (*immich.Album, error)
+	AddAssetsToAlbum(albumId string, assetIds []string) error
+	RemoveAssetsFromAlbum(albumId string, assetIds []string) error
+	GetAlbumsForAsset(assetId string) ([]immich.Album, error)
+	TrashAssets(assetIds []string) error
+	DeleteAssets(assetIds []string) error
+	RestoreAssets(assetIds []string) error
+	UploadAsset(u immich.AssetUpload) (string, bool, error)
+	SetLivePhotoVideo(assetId, videoId string) error
+	BulkUploadCheck(checksums map[string]string) (map[string]immich.BulkCheckResult, error)
+	SearchAssetsByDevice(deviceId string) (map[string]string, error)
+	SearchTrashedAssetsByDevice(deviceId string) (map[string]string, error)
+}
+
+var _ ImmichAPI = (*immich.Client)(nil)
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/immich"
+	"warreth.dev/immich-sync/pkg/metadata"
+	"warreth.dev/immich-sync/pkg/source"
+)
+
+// applyMetadata embeds capture date, caption and source into the upload according to
+// the writeMetadata setting, falling back to an XMP sidecar where files cannot be rewritten.
+func (a *App) applyMetadata(upload *immich.AssetUpload, mode string, p source.Item, ext string, isVideo bool, albumURL string) {
+	if mode != config.MetadataEmbed && mode != config.MetadataSidecar {
+		return
+	}
+	info := metadata.Info{TakenAt: p.TakenAt, Caption: p.Description, SourceURL: albumURL}
+
+	if mode == config.MetadataEmbed && !isVideo && metadata.IsJPEG(ext) {
+		out, added, complete, err := metadata.EmbedJPEG(upload.Reader, info)
+		upload.Reader = out
+		if err != nil {
+			a.Logger.Debug("Could not embed metadata, using sidecar", "id", p.ID, "error", err)
+		} else {
+			upload.Size += added
+			// The content changed, so the original checksum no longer identifies it
+			upload.Checksum = ""
+			if complete {
+				return
+			}
+		}
+	}
+	upload.Sidecar = metadata.BuildXMP(info)
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"strconv"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/metrics"
+	"warreth.dev/immich-sync/pkg/state"
+)
+
+// syncMetrics are the Prometheus metrics served on /metrics. Per-album series are labelled
+// with the album ID also used by /status.
+type syncMetrics struct {
+	registry *metrics.Registry
+
+	items           *metrics.CounterVec
+	bytesDownloaded *metrics.CounterVec
+	bytesUploaded   *metrics.CounterVec
+	runs            *metrics.CounterVec
+	duration        *metrics.HistogramVec
+	lastRun         *metrics.GaugeVec
+	lastSuccess     *metrics.GaugeVec
+	nextRun         *metrics.GaugeVec
+	googleRetries   *metrics.CounterVec
+	immichErrors    *metrics.CounterVec
+}
+
+func newSyncMetrics() *syncMetrics {
+	r := metrics.NewRegistry()
+	return &syncMetrics{
+		registry:        r,
+		items:           r.Counter("immich_sync_items_total", "Items processed by outcome: added, linked, failed or skipped-*.", "album", "outcome"),
+		bytesDownloaded: r.Counter("immich_sync_bytes_downloaded_total", "Bytes downloaded from the album source.", "album"),
+		bytesUploaded:   r.Counter("immich_sync_bytes_uploaded_total", "Bytes uploaded to Immich.", "album"),
+		runs:            r.Counter("immich_sync_runs_total", "Album syncs by result (success, partial, failed).", "album", "result"),
+		duration:        r.Histogram("immich_sync_duration_seconds", "Duration of album syncs.", metrics.DefBuckets, "album"),
+		lastRun:         r.Gauge("immich_sync_last_run_timestamp_seconds", "Unix time the last sync of the album started.", "album"),
+		lastSuccess:     r.Gauge("immich_sync_last_success_timestamp_seconds", "Unix time the last sync without an album-level error started.", "album"),
+		nextRun:         r.Gauge("immich_sync_next_run_timestamp_seconds", "Unix time of the next scheduled sync.", "album"),
+		googleRetries:   r.Counter("immich_sync_google_retries_total", "Google Photos requests retried after a 429 or 5xx response.", "status"),
+		immichErrors:    r.Counter("immich_sync_immich_api_errors_total", "Failed Immich API calls by endpoint; status 0 means no response.", "method", "endpoint", "status"),
+	}
+}
+
+// observeItem counts the outcome and transferred bytes of one processed item
+func (m *syncMetrics) observeItem(albumURL string, res processResult) {
+	album := albumID(albumURL)
+	m.items.Inc(album, res.outcome())
+	m.bytesDownloaded.Add(float64(res.BytesDownloaded), album)
+	m.bytesUploaded.Add(float64(res.BytesUploaded), album)
+}
+
+// observeRun records a finished sync; last run, last success and next run mirror the run log
+func (m *syncMetrics) observeRun(albumURL string, result SyncResult, run state.AlbumRun) {
+	album := albumID(albumURL)
+	m.runs.Inc(album, result.Status())
+	m.duration.Observe(result.Duration.Seconds(), album)
+	m.setRunTimes(albumURL, run)
+}
+
+// setRunTimes exports the timestamps of an album's run log entry
+func (m *syncMetrics) setRunTimes(albumURL string, run state.AlbumRun) {
+	album := albumID(albumURL)
+	setTimestamp(m.lastRun, run.LastRun, album)
+	setTimestamp(m.lastSuccess, run.LastSuccess, album)
+	setTimestamp(m.nextRun, run.NextRun, album)
+}
+
+// removeAlbum drops the gauges of an album that is no longer configured, so alerts on
+// stale timestamps stop firing for it
+func (m *syncMetrics) removeAlbum(albumURL string) {
+	album := albumID(albumURL)
+	m.lastRun.Delete(album)
+	m.lastSuccess.Delete(album)
+	m.nextRun.Delete(album)
+}
+
+func (m *syncMetrics) googleRetry(status int) {
+	m.googleRetries.Inc(strconv.Itoa(status))
+}
+
+func (m *syncMetrics) immichError(method, endpoint string, status int) {
+	m.immichErrors.Inc(method, endpoint, strconv.Itoa(status))
+}
+
+func setTimestamp(g *metrics.GaugeVec, t time.Time, labelValues ...string) {
+	if t.IsZero() {
+		return
+	}
+	g.Set(float64(t.UnixNano())/1e9, labelValues...)
+}
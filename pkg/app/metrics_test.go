@@ -0,0 +1,37 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSyncMetrics(t *testing.T) {
+	e := newTestEnv(t, nil)
+	e.src.add(3)
+	e.src.setFail("item002", true)
+	e.immich.FailNext(routeAddToAlbum, 1, 500)
+	e.sync()
+
+	var b bytes.Buffer
+	if _, err := e.app.metrics.registry.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		`immich_sync_items_total{album="testalbum",outcome="added"} 2`,
+		`immich_sync_items_total{album="testalbum",outcome="failed"} 1`,
+		`immich_sync_runs_total{album="testalbum",result="partial"} 1`,
+		`immich_sync_duration_seconds_bucket{album="testalbum",le="+Inf"} 1`,
+		`immich_sync_duration_seconds_count{album="testalbum"} 1`,
+		// The album ID in the request path is reduced to its route
+		`immich_sync_immich_api_errors_total{method="PUT",endpoint="albums/{id}/assets",status="500"} 1`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("missing %s in\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "bytes_uploaded_total{album=\"testalbum\"} 0\n") {
+		t.Errorf("no uploaded bytes counted in\n%s", out)
+	}
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/notify"
+)
+
+// notifyResult sends the outcome of a sync to the notifications the album opted into
+func (a *App) notifyResult(ac config.AlbumConfig, result SyncResult) {
+	if len(ac.Notify) == 0 {
+		return
+	}
+	triggers := syncTriggers(result)
+	if len(triggers) == 0 {
+		return
+	}
+
+	ev := notify.Event{
+		Triggers: triggers,
+		Album:    albumLabel(ac, result.Title),
+		AlbumID:  albumID(ac.URL),
+		URL:      ac.URL,
+		Status:   result.Status(),
+		Total:    result.Total,
+		New:      result.Added + result.Linked,
+		Added:    result.Added,
+		Linked:   result.Linked,
+		Skipped:  result.Skipped,
+		Failed:   result.Failed,
+		Duration: result.Duration.Round(time.Second),
+		Time:     time.Now(),
+	}
+	if result.Err != nil {
+		ev.Error = result.Err.Error()
+	}
+
+	notifications := a.config().Notifications
+	for _, name := range ac.Notify {
+		for _, n := range notifications {
+			if n.Name == name {
+				a.notifier.Notify(notificationTarget(n), ev)
+			}
+		}
+	}
+}
+
+// syncTriggers returns the notification triggers a sync result matches, most severe first
+func syncTriggers(result SyncResult) []string {
+	var triggers []string
+	if result.Unreachable {
+		triggers = append(triggers, config.EventUnreachable)
+	}
+	if result.Err != nil || result.Failed > 0 {
+		triggers = append(triggers, config.EventFailed)
+	}
+	if result.Err == nil && result.Total == 0 {
+		triggers = append(triggers, config.EventEmpty)
+	}
+	if result.Added+result.Linked > 0 {
+		triggers = append(triggers, config.EventAdded)
+	}
+	return triggers
+}
+
+// notificationTarget builds the channel of a validated notification config
+func notificationTarget(n config.NotificationConfig) notify.Target {
+	interval, _ := n.ParseMinInterval()
+	t := notify.Target{
+		Name:        n.Name,
+		Triggers:    n.Events,
+		Title:       n.Title,
+		Body:        n.Message,
+		MinInterval: interval,
+	}
+	switch n.Type {
+	case config.NotifyWebhook:
+		t.Sender = &notify.Webhook{URL: n.URL}
+	case config.NotifyNtfy:
+		t.Sender = &notify.Ntfy{URL: n.URL, Token: n.Token}
+	case config.NotifyGotify:
+		t.Sender = &notify.Gotify{URL: n.URL, Token: n.Token}
+	case config.NotifySMTP:
+		t.Sender = &notify.SMTP{URL: n.URL, From: n.From, To: n.Recipients()}
+	}
+	return t
+}
+
+// albumLabel is the album's configured name, its source title or its URL
+func albumLabel(ac config.AlbumConfig, title string) string {
+	if ac.AlbumName != "" {
+		return ac.AlbumName
+	}
+	if title != "" {
+		return title
+	}
+	return ac.URL
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/notify"
+)
+
+func TestSyncTriggers(t *testing.T) {
+	tests := []struct {
+		name   string
+		result SyncResult
+		want   string
+	}{
+		{"nothing new", SyncResult{Total: 3, Skipped: 3}, ""},
+		{"added", SyncResult{Total: 3, Added: 1, Skipped: 2}, "added"},
+		{"linked", SyncResult{Total: 3, Linked: 1, Skipped: 2}, "added"},
+		{"failed items", SyncResult{Total: 3, Added: 2, Failed: 1}, "failed,added"},
+		{"empty", SyncResult{}, "empty"},
+		{"error", SyncResult{Err: errors.New("album gone")}, "failed"},
+		{"unreachable", SyncResult{Err: errors.New("404"), Unreachable: true}, "unreachable,failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(syncTriggers(tt.result), ","); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// webhookEvents starts a webhook receiver and returns the events it has received so far
+func webhookEvents(t *testing.T) (string, func() []notify.Event) {
+	t.Helper()
+	var mu sync.Mutex
+	var events []notify.Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Event notify.Event `json:"event"`
+		}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("invalid webhook body %s: %v", data, err)
+		}
+		mu.Lock()
+		events = append(events, body.Event)
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, func() []notify.Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]notify.Event(nil), events...)
+	}
+}
+
+func TestSyncNotifies(t *testing.T) {
+	url, events := webhookEvents(t)
+	e := newTestEnv(t, func(c *config.Config) {
+		c.Notifications = []config.NotificationConfig{
+			{Name: "hook", Type: config.NotifyWebhook, URL: url, Events: []string{config.EventAdded, config.EventFailed}},
+			{Name: "unused", Type: config.NotifyWebhook, URL: url, Events: []string{config.EventAdded}},
+		}
+		c.Albums[0].Notify = []string{"hook"}
+	})
+	e.src.add(2)
+
+	e.sync()
+	got := events()
+	if len(got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(got))
+	}
+	if got[0].Trigger != config.EventAdded || got[0].Album != "Holiday" || got[0].Added != 2 || got[0].URL != testAlbumURL {
+		t.Errorf("got %+v", got[0])
+	}
+
+	// New items within minInterval (default 1h) of the last "added" notification are not announced again,
+	// but the first failure is
+	e.src.add(1)
+	e.sync()
+	e.src.add(1)
+	e.src.setFail("item003", true)
+	e.sync()
+	got = events()
+	if len(got) != 2 || got[1].Trigger != config.EventFailed || got[1].Failed != 1 {
+		t.Fatalf("got %+v, want the first notification and one failure", got)
+	}
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+)
+
+// configPollInterval is how often the config file is checked for changes
+const configPollInterval = 5 * time.Second
+
+// watchConfig reloads the config when the file or a conf.d fragment changes or the process receives SIGHUP
+func (a *App) watchConfig(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	a.reloadLoop(ctx, hup, ticker.C)
+}
+
+// reloadLoop reloads on every hup and on every tick that finds the config files changed
+func (a *App) reloadLoop(ctx context.Context, hup <-chan os.Signal, tick <-chan time.Time) {
+	last := a.configStamp()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			a.Logger.Info("Received SIGHUP, reloading config", "path", a.ConfigPath)
+		case <-tick:
+			if a.configStamp() == last {
+				continue
+			}
+			a.Logger.Info("Config file changed, reloading", "path", a.ConfigPath)
+		}
+		// Stamp before reading, so a change made during the reload triggers another one,
+		// and a SIGHUP reload is not repeated by the next poll
+		last = a.configStamp()
+		if err := a.Reload(); err != nil {
+			a.Logger.Error("Config reload failed, keeping the current config", "error", err)
+		}
+	}
+}
+
+// configStamp identifies the current version of the config file and its conf.d fragments
+func (a *App) configStamp() string {
+	files := []string{a.ConfigPath}
+	fragments, _ := config.ConfFiles(config.ConfDir(a.ConfigPath, a.config().ConfDir))
+	files = append(files, fragments...)
+
+	var b strings.Builder
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			fmt.Fprintf(&b, "%s %d %d\n", file, info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return b.String()
+}
+
+// Reload re-reads the config file and applies it to the running daemon. New albums are
+// scheduled immediately, removed ones are dropped and albums with a changed schedule are
+// rescheduled from their last run. Global settings apply to syncs that start after the
+// reload; in-flight syncs finish with the settings they started with.
+func (a *App) Reload() error {
+	cfg, err := config.Load(a.ConfigPath, a.Overrides)
+	if err != nil {
+		return err
+	}
+	for _, ac := range cfg.AllAlbums() {
+		if _, ok := a.Sources[ac.Source]; !ok {
+			return fmt.Errorf("album %s: unknown source %q", ac.URL, ac.Source)
+		}
+	}
+	for _, w := range cfg.Warnings {
+		a.Logger.Warn("Config warning", "detail", w)
+	}
+
+	old := a.config()
+	// Connection and storage settings are wired up at startup
+	if cfg.ApiURL != old.ApiURL || cfg.ApiKey != old.ApiKey || cfg.StateDir != old.StateDir || cfg.SpoolDir != old.SpoolDir || cfg.HTTPAddr != old.HTTPAddr {
+		a.Logger.Warn("Changes to apiURL, apiKey, stateDir, spoolDir and httpAddr require a restart")
+		cfg.ApiURL, cfg.ApiKey, cfg.StateDir, cfg.SpoolDir, cfg.HTTPAddr = old.ApiURL, old.ApiKey, old.StateDir, old.SpoolDir, old.HTTPAddr
+	}
+
+	a.cfgMu.Lock()
+	a.Cfg = cfg
+	a.cfgMu.Unlock()
+
+	if cfg.Debug {
+		a.logLevel.Set(slog.LevelDebug)
+	} else {
+		a.logLevel.Set(slog.LevelInfo)
+	}
+
+	if a.Scheduler == nil {
+		return nil
+	}
+	if cfg.AlbumWorkers != old.AlbumWorkers {
+		a.Scheduler.SetWorkers(cfg.AlbumWorkers)
+	}
+
+	a.albumsMu.Lock()
+	defer a.albumsMu.Unlock()
+
+	seen := make(map[string]bool)
+	added, removed, changed := 0, 0, 0
+	for _, ac := range cfg.AllAlbums() {
+		seen[ac.URL] = true
+		prev, ok := a.albums[ac.URL]
+		a.albums[ac.URL] = ac
+		switch {
+		case !ok:
+			a.Logger.Info("Album added", "album", ac.URL)
+			a.Scheduler.Add(ac.URL, time.Now())
+			added++
+		case !reflect.DeepEqual(prev, ac):
+			changed++
+			if prev.SyncInterval != ac.SyncInterval || prev.Schedule != ac.Schedule || prev.Timezone != ac.Timezone {
+				a.Scheduler.Add(ac.URL, a.resumeAt(ac))
+			}
+		}
+	}
+	for url := range a.albums {
+		if !seen[url] {
+			a.Logger.Info("Album removed", "album", url)
+			a.Scheduler.Remove(url)
+			a.metrics.removeAlbum(url)
+			delete(a.albums, url)
+			removed++
+		}
+	}
+
+	a.Logger.Info("Config reloaded", "albums", len(a.albums), "added", added, "removed", removed, "changed", changed,
+		"workers", cfg.Workers, "album_workers", cfg.AlbumWorkers, "debug", cfg.Debug)
+	return nil
+}
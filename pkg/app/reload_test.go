@@ -0,0 +1,224 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/scheduler"
+	"warreth.dev/immich-sync/pkg/state"
+)
+
+const (
+	reloadAlbumA = "https://photos.app.goo.gl/albumA"
+	reloadAlbumB = "https://photos.app.goo.gl/albumB"
+	reloadAlbumC = "https://photos.app.goo.gl/albumC"
+	reloadAlbumD = "https://photos.app.goo.gl/albumD"
+)
+
+// writeReloadConfig points the app at a config file with the given album entries (JSON objects)
+func (e *testEnv) writeReloadConfig(extra string, albums ...string) {
+	e.t.Helper()
+	cfg := e.app.config()
+	if e.app.ConfigPath == "" {
+		e.app.ConfigPath = filepath.Join(e.t.TempDir(), "config.json")
+	}
+	data := fmt.Sprintf(`{"apiKey": %q, "apiURL": %q, "stateDir": %q, %s"albums": [%s]}`,
+		cfg.ApiKey, cfg.ApiURL, cfg.StateDir, extra, strings.Join(albums, ", "))
+	if err := os.WriteFile(e.app.ConfigPath, []byte(data), 0o644); err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+// startScheduler schedules the configured albums like Run does, without running them
+func (e *testEnv) startScheduler() {
+	e.t.Helper()
+	if err := e.app.Reload(); err != nil {
+		e.t.Fatal(err)
+	}
+	e.app.albums = make(map[string]config.AlbumConfig)
+	e.app.Scheduler = scheduler.New(1, func(string) time.Time { return time.Now().Add(time.Hour) })
+	for _, ac := range e.app.config().AllAlbums() {
+		e.app.albums[ac.URL] = ac
+		e.app.Scheduler.Add(ac.URL, e.app.resumeAt(ac))
+	}
+}
+
+// scheduled returns the next run of every scheduled album
+func (e *testEnv) scheduled() map[string]time.Time {
+	next := make(map[string]time.Time)
+	for _, entry := range e.app.Schedule() {
+		next[entry.Key] = entry.Next
+	}
+	return next
+}
+
+func TestReload(t *testing.T) {
+	e := newTestEnv(t, nil)
+	lastRun := time.Now().Add(-30 * time.Minute)
+	for _, url := range []string{reloadAlbumA, reloadAlbumB, reloadAlbumD} {
+		e.app.recordRun(config.AlbumConfig{URL: url}, lastRun, SyncResult{Total: 1}, lastRun.Add(12*time.Hour))
+	}
+	e.writeReloadConfig(`"albumWorkers": 1, `,
+		fmt.Sprintf(`{"url": %q, "syncInterval": "12h"}`, reloadAlbumA),
+		fmt.Sprintf(`{"url": %q, "syncInterval": "12h"}`, reloadAlbumB),
+		fmt.Sprintf(`{"url": %q, "syncInterval": "12h", "albumName": "D"}`, reloadAlbumD),
+	)
+	e.startScheduler()
+	before := e.scheduled()
+	if len(before) != 3 || !before[reloadAlbumA].Equal(lastRun.Add(12*time.Hour)) {
+		t.Fatalf("scheduled %v", before)
+	}
+
+	// A gets a shorter interval, B is removed, C is new and D only changes its name
+	e.writeReloadConfig(`"albumWorkers": 2, "debug": true, "stateDir": "elsewhere", `,
+		fmt.Sprintf(`{"url": %q, "syncInterval": "1h"}`, reloadAlbumA),
+		fmt.Sprintf(`{"url": %q, "syncInterval": "12h"}`, reloadAlbumC),
+		fmt.Sprintf(`{"url": %q, "syncInterval": "12h", "albumName": "Renamed"}`, reloadAlbumD),
+	)
+	reloaded := time.Now()
+	if err := e.app.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	after := e.scheduled()
+	if len(after) != 3 {
+		t.Fatalf("scheduled %v, want A, C and D", after)
+	}
+	if _, ok := after[reloadAlbumB]; ok {
+		t.Error("removed album B is still scheduled")
+	}
+	if want := lastRun.Add(time.Hour); !after[reloadAlbumA].Equal(want) {
+		t.Errorf("A scheduled at %v, want %v from its last run and new interval", after[reloadAlbumA], want)
+	}
+	if next := after[reloadAlbumC]; next.Before(reloaded) || next.After(time.Now()) {
+		t.Errorf("new album C scheduled at %v, want now", next)
+	}
+	if !after[reloadAlbumD].Equal(before[reloadAlbumD]) {
+		t.Errorf("D rescheduled to %v after a name change", after[reloadAlbumD])
+	}
+	if got := e.app.albums[reloadAlbumD].AlbumName; got != "Renamed" {
+		t.Errorf("D has name %q after the reload", got)
+	}
+
+	cfg := e.app.config()
+	if cfg.AlbumWorkers != 2 || !cfg.Debug || e.app.logLevel.Level() != slog.LevelDebug {
+		t.Errorf("global settings not applied: albumWorkers %d, debug %v", cfg.AlbumWorkers, cfg.Debug)
+	}
+	if cfg.StateDir == "elsewhere" {
+		t.Error("stateDir changed without a restart")
+	}
+}
+
+func TestReloadInvalid(t *testing.T) {
+	e := newTestEnv(t, nil)
+	e.writeReloadConfig("", fmt.Sprintf(`{"url": %q}`, reloadAlbumA))
+	e.startScheduler()
+
+	e.writeReloadConfig(`"workers": -1, `, fmt.Sprintf(`{"url": %q}`, reloadAlbumB))
+	if err := e.app.Reload(); err == nil {
+		t.Fatal("expected a validation error")
+	}
+	if next := e.scheduled(); len(next) != 1 || next[reloadAlbumA].IsZero() {
+		t.Errorf("scheduled %v, want the old albums", next)
+	}
+	if albums := e.app.config().AllAlbums(); len(albums) != 1 || albums[0].URL != reloadAlbumA {
+		t.Errorf("config replaced by an invalid one: %v", albums)
+	}
+}
+
+func TestReloadLoop(t *testing.T) {
+	e := newTestEnv(t, nil)
+	e.writeReloadConfig("", fmt.Sprintf(`{"url": %q}`, reloadAlbumA))
+	e.startScheduler()
+	var logs bytes.Buffer
+	e.app.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hup := make(chan os.Signal)
+	tick := make(chan time.Time)
+	done := make(chan struct{})
+	go func() {
+		e.app.reloadLoop(ctx, hup, tick)
+		close(done)
+	}()
+
+	tick <- time.Now() // unchanged: no reload
+	e.writeReloadConfig("", fmt.Sprintf(`{"url": %q}`, reloadAlbumA), fmt.Sprintf(`{"url": %q}`, reloadAlbumB))
+	hup <- os.Interrupt
+	// The SIGHUP reload has seen the change; polling must not reload it again
+	tick <- time.Now()
+	tick <- time.Now()
+	e.writeReloadConfig("", fmt.Sprintf(`{"url": %q}`, reloadAlbumC))
+	tick <- time.Now()
+	tick <- time.Now()
+	cancel()
+	<-done
+
+	if n := strings.Count(logs.String(), "Config reloaded"); n != 2 {
+		t.Errorf("reloaded %d times, want 2 (SIGHUP and the second file change):\n%s", n, logs.String())
+	}
+	if next := e.scheduled(); len(next) != 1 || next[reloadAlbumC].IsZero() {
+		t.Errorf("scheduled %v, want album C only", next)
+	}
+}
+
+func TestResumeAt(t *testing.T) {
+	now := time.Now()
+	ac := config.AlbumConfig{URL: reloadAlbumA, SyncInterval: "12h"}
+	tests := []struct {
+		name string
+		run  *state.AlbumRun // nil if the album never synced
+		want time.Time       // zero for now
+	}{
+		{"never synced", nil, time.Time{}},
+		// e.g. a long sync: the next run counts from when it finished, not from when it started
+		{"recorded next run", &state.AlbumRun{LastRun: now.Add(-time.Hour), NextRun: now.Add(12 * time.Hour), Schedule: "every 12h"}, now.Add(12 * time.Hour)},
+		{"recorded next run passed", &state.AlbumRun{LastRun: now.Add(-13 * time.Hour), NextRun: now.Add(-time.Hour), Schedule: "every 12h"}, time.Time{}},
+		{"schedule changed", &state.AlbumRun{LastRun: now.Add(-time.Hour), NextRun: now.Add(23 * time.Hour), Schedule: "every 24h"}, now.Add(11 * time.Hour)},
+		{"recorded without schedule", &state.AlbumRun{LastRun: now.Add(-time.Hour), NextRun: now.Add(2 * time.Hour)}, now.Add(11 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t, nil)
+			if tt.run != nil {
+				if err := e.app.Runs.Put(ac.URL, *tt.run); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := time.Now()
+			got := e.app.resumeAt(ac)
+			if tt.want.IsZero() {
+				if got.Before(before) || got.After(time.Now()) {
+					t.Errorf("got %v, want now", got)
+				}
+			} else if !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordRunSchedule(t *testing.T) {
+	e := newTestEnv(t, nil)
+	for _, tt := range []struct {
+		ac   config.AlbumConfig
+		want string
+	}{
+		{config.AlbumConfig{URL: reloadAlbumA}, "every 24h0m0s"},
+		{config.AlbumConfig{URL: reloadAlbumB, SyncInterval: "6h"}, "every 6h"},
+		{config.AlbumConfig{URL: reloadAlbumC, Schedule: "0 3 * * *", Timezone: "Europe/Berlin"}, "0 3 * * * Europe/Berlin"},
+	} {
+		e.app.recordRun(tt.ac, time.Now(), SyncResult{}, time.Now().Add(time.Hour))
+		if run, _ := e.app.Runs.Get(tt.ac.URL); run.Schedule != tt.want {
+			t.Errorf("%s: recorded schedule %q, want %q", tt.ac.URL, run.Schedule, tt.want)
+		}
+	}
+}
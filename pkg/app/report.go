@@ -0,0 +1,38 @@
+package app
+
+import (
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/report"
+)
+
+// writeReport saves the JSON and CSV report of a finished sync to reportsDir, if set,
+// and removes the album's oldest reports beyond keepReports
+func (a *App) writeReport(ac config.AlbumConfig, started time.Time, result SyncResult) {
+	cfg := a.config()
+	if cfg.ReportsDir == "" {
+		return
+	}
+	r := report.New(result.Items)
+	r.Album = albumLabel(ac, result.Title)
+	r.AlbumID = albumID(ac.URL)
+	r.URL = ac.URL
+	r.Status = result.Status()
+	if result.Err != nil {
+		r.Error = result.Err.Error()
+	}
+	r.StartedAt = started
+	r.FinishedAt = time.Now()
+	r.Duration = result.Duration.Seconds()
+
+	path, err := r.Write(cfg.ReportsDir)
+	if err != nil {
+		a.Logger.Warn("Failed to write sync report", "album", ac.URL, "error", err)
+		return
+	}
+	a.Logger.Debug("Wrote sync report", "album", ac.URL, "path", path)
+	if err := report.Prune(cfg.ReportsDir, r.AlbumID, cfg.KeepReports); err != nil {
+		a.Logger.Warn("Failed to remove old sync reports", "album", ac.URL, "error", err)
+	}
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/report"
+)
+
+func TestSyncReport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reports")
+	e := newTestEnv(t, func(c *config.Config) {
+		c.ReportsDir = dir
+		c.KeepReports = 2
+	})
+	e.src.add(3)
+	e.src.setFail("item002", true)
+
+	// Older reports of this album and of another one
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		for _, id := range []string{albumID(testAlbumURL), "other"} {
+			r := report.New(nil)
+			r.AlbumID = id
+			r.StartedAt = old.Add(time.Duration(i) * time.Hour)
+			if _, err := r.Write(dir); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	e.sync()
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	var names []string
+	for _, m := range matches {
+		names = append(names, filepath.Base(m))
+	}
+	sort.Strings(names)
+	if len(names) != 4 || names[0] != "20240101T000000Z_other.json" || names[1] != "20240101T010000Z_other.json" ||
+		names[2] != "20240101T010000Z_testalbum.json" || !strings.HasSuffix(names[3], "_testalbum.json") {
+		t.Fatalf("got reports %v, want both of the other album and the two newest of this one", names)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "20240101T000000Z_testalbum.csv")); !os.IsNotExist(err) {
+		t.Errorf("CSV of the pruned report still exists: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, names[3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r report.Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Album != "Holiday" || r.URL != testAlbumURL || r.Status != "partial" || r.Summary.Total != 3 {
+		t.Errorf("got %+v", r)
+	}
+	if r.Summary.Outcomes[report.Added] != 2 || r.Summary.Outcomes[report.Failed] != 1 {
+		t.Errorf("got outcomes %v", r.Summary.Outcomes)
+	}
+	for _, item := range r.Items {
+		if (item.ItemID == "item002") != (item.Outcome == report.Failed) || (item.Outcome == report.Failed && item.Error == "") {
+			t.Errorf("got item %+v", item)
+		}
+	}
+}
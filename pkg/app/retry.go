@@ -0,0 +1,227 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/report"
+	"warreth.dev/immich-sync/pkg/source"
+	"warreth.dev/immich-sync/pkg/state"
+)
+
+// Error classes of failed items in the retry queue
+const (
+	errClassDownload = "download" // the item could not be downloaded from the source
+	errClassUpload   = "upload"   // Immich rejected or failed the upload
+	errClassRestore  = "restore"  // the trashed asset could not be restored, or deleted for a reupload
+	errClassOther    = "other"
+)
+
+// retryCheckInterval is how often the daemon looks for failed items that are due for a retry
+const retryCheckInterval = time.Minute
+
+// maxRetryDelay caps the exponential backoff between two retries of an item
+const maxRetryDelay = 24 * time.Hour
+
+// trackFailure queues a failed item for a retry, or moves it to the dead-letter list after
+// retryAttempts failures. Items that synced are removed from the queue. Changes are saved
+// once at the end of the album run.
+func (a *App) trackFailure(ac config.AlbumConfig, res processResult) {
+	if res.Error == nil {
+		if res.Skipped == report.SkippedDead {
+			return
+		}
+		a.Retries.Delete(ac.URL, res.ItemID)
+		return
+	}
+
+	cfg := a.config()
+	now := time.Now()
+	f, ok := a.Retries.Get(ac.URL, res.ItemID)
+	if !ok {
+		f = state.Failure{Album: ac.URL, ItemID: res.ItemID, FirstFailed: now}
+	}
+	f.URL = res.URL
+	f.Class = res.ErrorClass
+	if f.Class == "" {
+		f.Class = errClassOther
+	}
+	f.Error = res.Error.Error()
+	f.Attempts++
+	f.LastFailed = now
+	if f.Attempts >= cfg.RetryAttempts {
+		f.Dead, f.NextRetry = true, time.Time{}
+		a.Logger.Warn("Item failed too often, moved to the dead-letter list", "album", ac.URL, "item", res.ItemID, "attempts", f.Attempts, "class", f.Class)
+	} else {
+		backoff, _ := cfg.ParseRetryBackoff()
+		f.NextRetry = now.Add(retryDelay(backoff, f.Attempts))
+	}
+	a.Retries.Put(f)
+}
+
+// retryDelay doubles the backoff with every failed attempt
+func retryDelay(backoff time.Duration, attempts int) time.Duration {
+	delay := backoff
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// retryLoop retries failed items whose backoff has expired, independent of the album schedules
+func (a *App) retryLoop(ctx context.Context) {
+	ticker := time.NewTicker(retryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.retryDue()
+		}
+	}
+}
+
+// retryDue retries the due items of every scheduled album
+func (a *App) retryDue() {
+	failures, err := a.Retries.List()
+	if err != nil {
+		a.Logger.Warn("Failed to read retry queue", "error", err)
+		return
+	}
+	now := time.Now()
+	due := make(map[string]map[string]bool) // album URL -> item IDs
+	for _, f := range failures {
+		if f.Dead || f.NextRetry.After(now) {
+			continue
+		}
+		if due[f.Album] == nil {
+			due[f.Album] = make(map[string]bool)
+		}
+		due[f.Album][f.ItemID] = true
+	}
+
+	for url, ids := range due {
+		a.albumsMu.Lock()
+		ac, ok := a.albums[url]
+		a.albumsMu.Unlock()
+		if !ok {
+			// The album was removed from the config; nothing will sync these items again
+			a.Logger.Info("Dropping failed items of removed album", "album", url, "count", len(ids))
+			a.Retries.Update(func(f state.Failure) bool { return f.Album == url }, func(*state.Failure) bool { return false })
+			continue
+		}
+		a.retryAlbum(ac, ids)
+	}
+}
+
+// retryAlbum re-fetches an album, for fresh download URLs, and processes only the given items.
+// It takes an album worker slot like a scheduled sync; when none is free, or the album is
+// syncing, the items wait for the next check.
+func (a *App) retryAlbum(ac config.AlbumConfig, ids map[string]bool) {
+	if !a.Scheduler.TryAcquire() {
+		return
+	}
+	defer a.Scheduler.Release()
+	lock := a.albumLock(ac.URL)
+	if !lock.TryLock() {
+		return
+	}
+	defer lock.Unlock()
+
+	albumCache, err := a.Client.GetAlbums()
+	if err != nil {
+		a.Logger.Warn("Failed to fetch Immich album list", "error", err)
+	}
+	result := a.processAlbum(ac, albumCache, ids)
+	if result.Err != nil {
+		a.Logger.Warn("Retry of failed items failed", "album", ac.URL, "error", result.Err)
+	} else {
+		a.Logger.Info("Retried failed items", "album", ac.URL, "added", result.Added, "linked", result.Linked, "failed", result.Failed)
+	}
+
+	// Items that were not processed, e.g. because the album was unreachable, wait for their next backoff
+	now := time.Now()
+	backoff, _ := a.config().ParseRetryBackoff()
+	a.Retries.Update(func(f state.Failure) bool {
+		return f.Album == ac.URL && ids[f.ItemID] && !f.Dead && !f.NextRetry.After(now)
+	}, func(f *state.Failure) bool {
+		f.NextRetry = now.Add(retryDelay(backoff, f.Attempts))
+		return true
+	})
+}
+
+// retryItems returns the album items to retry and drops queued items that left the album
+func (a *App) retryItems(ac config.AlbumConfig, items []source.Item, ids map[string]bool) []source.Item {
+	found := make(map[string]bool, len(ids))
+	var retry []source.Item
+	for _, p := range items {
+		if ids[p.ID] {
+			retry = append(retry, p)
+			found[p.ID] = true
+		}
+	}
+	for id := range ids {
+		if !found[id] {
+			a.Logger.Info("Failed item is no longer in the album, dropping it", "album", ac.URL, "item", id)
+			a.Retries.Delete(ac.URL, id)
+		}
+	}
+	return retry
+}
+
+// Failures returns the queued and dead-lettered items of the album matching ref
+// (see findAlbum), or of every album if ref is empty
+func (a *App) Failures(ref string) ([]state.Failure, error) {
+	failures, err := a.Retries.List()
+	if err != nil {
+		return nil, err
+	}
+	match := a.albumMatcher(ref)
+	out := failures[:0]
+	for _, f := range failures {
+		if match(f) {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+// RetryDeadLetters moves dead-lettered items back into the retry queue with a fresh attempt
+// count. The daemon retries them within a minute; otherwise the next sync does.
+func (a *App) RetryDeadLetters(ref string) (int, error) {
+	match := a.albumMatcher(ref)
+	now := time.Now()
+	return a.Retries.Update(func(f state.Failure) bool {
+		return f.Dead && match(f)
+	}, func(f *state.Failure) bool {
+		f.Dead, f.Attempts, f.NextRetry = false, 0, now
+		return true
+	})
+}
+
+// ClearDeadLetters forgets dead-lettered items, so the next sync of their album tries them again
+func (a *App) ClearDeadLetters(ref string) (int, error) {
+	match := a.albumMatcher(ref)
+	return a.Retries.Update(func(f state.Failure) bool {
+		return f.Dead && match(f)
+	}, func(*state.Failure) bool {
+		return false
+	})
+}
+
+// albumMatcher selects the failures of the album matching ref, by ID, URL or title
+func (a *App) albumMatcher(ref string) func(state.Failure) bool {
+	if ref == "" {
+		return func(state.Failure) bool { return true }
+	}
+	url := ref // albums that are no longer configured can still be matched by URL
+	if s, ok := a.findAlbum(ref); ok {
+		url = s.Album.URL
+	}
+	return func(f state.Failure) bool { return f.Album == url }
+}
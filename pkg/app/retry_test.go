@@ -0,0 +1,187 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/report"
+	"warreth.dev/immich-sync/pkg/scheduler"
+	"warreth.dev/immich-sync/pkg/state"
+)
+
+// newRetryEnv prepares the daemon state retryDue relies on: the scheduled albums and the scheduler
+func newRetryEnv(t *testing.T, workers int) *testEnv {
+	e := newTestEnv(t, func(c *config.Config) { c.RetryAttempts = 3 })
+	ac := e.app.Cfg.Albums[0]
+	e.app.albums = map[string]config.AlbumConfig{ac.URL: ac}
+	e.app.Scheduler = scheduler.New(workers, func(string) time.Time { return time.Now().Add(time.Hour) })
+	return e
+}
+
+// makeDue moves every queued retry into the past
+func (e *testEnv) makeDue() {
+	e.app.Retries.Update(func(state.Failure) bool { return true }, func(f *state.Failure) bool {
+		if !f.Dead {
+			f.NextRetry = time.Now().Add(-time.Second)
+		}
+		return true
+	})
+}
+
+func (e *testEnv) failure(itemID string) state.Failure {
+	e.t.Helper()
+	f, ok := e.app.Retries.Get(testAlbumURL, itemID)
+	if !ok {
+		e.t.Fatalf("%s not in the retry queue", itemID)
+	}
+	return f
+}
+
+func TestRetryQueue(t *testing.T) {
+	e := newRetryEnv(t, 1)
+	e.src.add(3)
+	e.src.setFail("item001", true)
+
+	e.sync()
+	f := e.failure("item001")
+	if f.Attempts != 1 || f.Class != errClassDownload || f.Dead || f.URL == "" {
+		t.Fatalf("after the first failure: %+v", f)
+	}
+	if d := time.Until(f.NextRetry); d < 4*time.Minute || d > 5*time.Minute {
+		t.Errorf("first retry in %s, want the 5m default backoff", d)
+	}
+
+	// Not due yet: nothing happens
+	downloads := e.src.downloadCount()
+	e.app.retryDue()
+	if e.src.downloadCount() != downloads {
+		t.Error("retried before the backoff expired")
+	}
+
+	// The retry processes only the failed item and doubles the backoff
+	e.makeDue()
+	e.app.retryDue()
+	if got := e.src.downloadCount(); got != downloads+1 {
+		t.Errorf("%d downloads in the retry, want 1", got-downloads)
+	}
+	f = e.failure("item001")
+	if d := time.Until(f.NextRetry); f.Attempts != 2 || d < 9*time.Minute || d > 10*time.Minute {
+		t.Errorf("after the second failure: attempts %d, next retry in %s", f.Attempts, d)
+	}
+
+	// The third failure dead-letters it
+	e.makeDue()
+	e.app.retryDue()
+	if f = e.failure("item001"); !f.Dead || f.Attempts != 3 || !f.NextRetry.IsZero() {
+		t.Fatalf("after the third failure: %+v", f)
+	}
+
+	// Syncs and dry runs skip it; the source is not asked for it again
+	e.src.setFail("item001", false)
+	downloads = e.src.downloadCount()
+	res := e.sync()
+	if got := outcomes(res)["item001"]; got != report.SkippedDead {
+		t.Errorf("sync outcome %s, want %s", got, report.SkippedDead)
+	}
+	if e.src.downloadCount() != downloads {
+		t.Error("dead-lettered item downloaded")
+	}
+	plans, err := e.app.DryRun(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range plans[0].Items {
+		if item.ItemID == "item001" && item.Action != PlanSkipDead {
+			t.Errorf("dry-run action %s, want %s", item.Action, PlanSkipDead)
+		}
+	}
+
+	// Retrying the dead letter requeues it; the next check syncs it and clears the queue
+	if n, err := e.app.RetryDeadLetters("Holiday"); n != 1 || err != nil {
+		t.Fatalf("RetryDeadLetters = %d, %v", n, err)
+	}
+	if f = e.failure("item001"); f.Dead || f.Attempts != 0 {
+		t.Errorf("requeued: %+v", f)
+	}
+	e.app.retryDue()
+	if failures, _ := e.app.Retries.List(); len(failures) != 0 {
+		t.Errorf("queue not empty: %+v", failures)
+	}
+	if len(e.album().AssetIds) != 3 {
+		t.Errorf("album has %d assets, want 3", len(e.album().AssetIds))
+	}
+}
+
+func TestRetryRespectsAlbumWorkers(t *testing.T) {
+	e := newRetryEnv(t, 1)
+	e.src.add(2)
+	e.src.setFail("item000", true)
+	e.sync()
+	e.makeDue()
+
+	// Every album worker is busy: the retry waits for the next check
+	if !e.app.Scheduler.TryAcquire() {
+		t.Fatal("no free worker slot")
+	}
+	downloads := e.src.downloadCount()
+	e.app.retryDue()
+	if e.src.downloadCount() != downloads {
+		t.Error("retried without a free album worker")
+	}
+	e.app.Scheduler.Release()
+
+	e.src.setFail("item000", false)
+	e.app.retryDue()
+	if failures, _ := e.app.Retries.List(); len(failures) != 0 {
+		t.Errorf("queue not empty: %+v", failures)
+	}
+}
+
+func TestRetryDropsItems(t *testing.T) {
+	e := newRetryEnv(t, 1)
+	e.src.add(2)
+	e.src.setFail("item000", true)
+	e.src.setFail("item001", true)
+	e.sync()
+
+	// Items that left the album are dropped instead of retried
+	e.src.remove("item000")
+	e.makeDue()
+	e.app.retryDue()
+	if _, ok := e.app.Retries.Get(testAlbumURL, "item000"); ok {
+		t.Error("removed item still queued")
+	}
+
+	// So are the items of albums removed from the config
+	e.app.albums = map[string]config.AlbumConfig{}
+	e.makeDue()
+	e.app.retryDue()
+	if failures, _ := e.app.Retries.List(); len(failures) != 0 {
+		t.Errorf("queue not empty: %+v", failures)
+	}
+}
+
+func TestClearDeadLetters(t *testing.T) {
+	e := newRetryEnv(t, 1)
+	e.src.add(2)
+	e.src.setFail("item000", true)
+	for i := 0; i < 3; i++ {
+		e.sync()
+	}
+	if f := e.failure("item000"); !f.Dead {
+		t.Fatalf("not dead-lettered after 3 syncs: %+v", f)
+	}
+
+	if n, _ := e.app.ClearDeadLetters("https://photos.app.goo.gl/other"); n != 0 {
+		t.Errorf("cleared %d items of another album", n)
+	}
+	if n, err := e.app.ClearDeadLetters(""); n != 1 || err != nil {
+		t.Fatalf("ClearDeadLetters = %d, %v", n, err)
+	}
+	// The next sync tries the item again
+	e.src.setFail("item000", false)
+	if res := e.sync(); outcomes(res)["item000"] != report.Added {
+		t.Errorf("outcome after clearing: %s", outcomes(res)["item000"])
+	}
+}
@@ -0,0 +1,222 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/progress"
+)
+
+// readyCheckInterval is how often the HTTP server re-checks the Immich connection for /readyz
+const readyCheckInterval = time.Minute
+
+// startServer listens on httpAddr and serves the status endpoints until ctx is cancelled.
+// Listening happens before it returns so a bad address fails startup.
+func (a *App) startServer(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler:           a.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	a.Logger.Info("HTTP server listening", "addr", ln.Addr().String())
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.Logger.Error("HTTP server stopped", "error", err)
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(readyCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				srv.Shutdown(shutdownCtx)
+				cancel()
+				return
+			case <-ticker.C:
+				if _, _, err := a.checkImmich(); err != nil {
+					a.Logger.Warn("Immich connection check failed", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Handler returns the HTTP API of the daemon:
+//
+//	GET  /healthz        process liveness
+//	GET  /readyz         result of the last Immich connection check
+//	GET  /status         every album's last run, next run and counts
+//	POST /sync/{album}   sync an album now; album is its ID from /status, URL or title
+//	GET  /metrics        Prometheus metrics
+func (a *App) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", a.handleHealthz)
+	mux.HandleFunc("GET /readyz", a.handleReadyz)
+	mux.HandleFunc("GET /status", a.handleStatus)
+	mux.HandleFunc("POST /sync/{album}", a.handleSync)
+	mux.Handle("GET /metrics", a.metrics.registry.Handler())
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func (a *App) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (a *App) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	a.healthMu.Lock()
+	checked, err := a.lastCheck, a.checkErr
+	a.healthMu.Unlock()
+
+	resp := map[string]interface{}{"ready": err == nil && !checked.IsZero()}
+	if !checked.IsZero() {
+		resp["checkedAt"] = checked
+	}
+	status := http.StatusOK
+	switch {
+	case checked.IsZero():
+		resp["error"] = "Immich connection not checked yet"
+		status = http.StatusServiceUnavailable
+	case err != nil:
+		resp["error"] = err.Error()
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+// albumStatusJSON is one album of the /status response
+type albumStatusJSON struct {
+	ID          string             `json:"id"`
+	URL         string             `json:"url"`
+	Title       string             `json:"title,omitempty"`
+	Running     bool               `json:"running"`
+	NextRun     *time.Time         `json:"nextRun,omitempty"`
+	LastRun     *time.Time         `json:"lastRun,omitempty"`
+	LastSuccess *time.Time         `json:"lastSuccess,omitempty"`
+	LastResult  string             `json:"lastResult,omitempty"`
+	LastError   string             `json:"lastError,omitempty"`
+	Total       int                `json:"total"`
+	Added       int                `json:"added"`
+	Skipped     int                `json:"skipped"`
+	Failed      int                `json:"failed"`
+	Duration    float64            `json:"durationSeconds"`
+	Progress    *progress.Snapshot `json:"progress,omitempty"` // counts of the sync in progress
+}
+
+func (a *App) handleStatus(w http.ResponseWriter, r *http.Request) {
+	next := make(map[string]time.Time)
+	running := make(map[string]bool)
+	for _, e := range a.Schedule() {
+		next[e.Key] = e.Next
+		running[e.Key] = e.Running
+	}
+
+	albums := []albumStatusJSON{}
+	for _, s := range a.Status() {
+		ac, run := s.Album, s.Run
+		st := albumStatusJSON{
+			ID:         albumID(ac.URL),
+			URL:        ac.URL,
+			Title:      run.Title,
+			Running:    running[ac.URL],
+			LastRun:    timePtr(run.LastRun),
+			LastResult: run.LastResult,
+			LastError:  run.LastError,
+			Total:      run.Total,
+			Added:      run.Added,
+			Skipped:    run.Skipped,
+			Failed:     run.Failed,
+			Duration:   run.Duration.Seconds(),
+		}
+		if ac.AlbumName != "" {
+			st.Title = ac.AlbumName
+		}
+		st.LastSuccess = timePtr(run.LastSuccess)
+		if t, ok := next[ac.URL]; ok && !st.Running {
+			st.NextRun = timePtr(t)
+		}
+		a.trackersMu.Lock()
+		if tracker, ok := a.trackers[ac.URL]; ok {
+			snapshot := tracker.Snapshot()
+			st.Progress = &snapshot
+		}
+		a.trackersMu.Unlock()
+		albums = append(albums, st)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"albums": albums})
+}
+
+func (a *App) handleSync(w http.ResponseWriter, r *http.Request) {
+	ref := r.PathValue("album")
+	s, ok := a.findAlbum(ref)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no configured album matches " + ref})
+		return
+	}
+	ac := s.Album
+	if a.Scheduler == nil || !a.Scheduler.Trigger(ac.URL) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "album is not scheduled"})
+		return
+	}
+	a.Logger.Info("Sync triggered via HTTP", "album", ac.URL)
+
+	status := "queued"
+	for _, e := range a.Schedule() {
+		if e.Key == ac.URL && e.Running {
+			status = "running" // already syncing; it is not started twice
+		}
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"id": albumID(ac.URL), "url": ac.URL, "status": status})
+}
+
+// findAlbum resolves a /sync reference: album ID, URL or title (case-insensitive)
+func (a *App) findAlbum(ref string) (AlbumStatus, bool) {
+	for _, s := range a.Status() {
+		title := s.Album.AlbumName
+		if title == "" {
+			title = s.Run.Title
+		}
+		if ref == albumID(s.Album.URL) || ref == s.Album.URL || (title != "" && strings.EqualFold(ref, title)) {
+			return s, true
+		}
+	}
+	return AlbumStatus{}, false
+}
+
+// albumID derives a short, URL-safe album identifier from its share link: the last path segment,
+// e.g. the media key of photos.google.com/share/<key> or the code of a photos.app.goo.gl link
+func albumID(albumURL string) string {
+	u, err := url.Parse(albumURL)
+	if err != nil {
+		return albumURL
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return parts[len(parts)-1]
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/scheduler"
+)
+
+// serve sends a request to the app's HTTP API and decodes the JSON response into v
+func (e *testEnv) serve(method, path string, v interface{}) int {
+	e.t.Helper()
+	rec := httptest.NewRecorder()
+	e.app.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		e.t.Fatalf("%s %s: got content type %q", method, path, ct)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		e.t.Fatalf("%s %s: invalid JSON %s: %v", method, path, rec.Body, err)
+	}
+	return rec.Code
+}
+
+func TestServerHealthz(t *testing.T) {
+	e := newTestEnv(t, nil)
+	var body map[string]string
+	if code := e.serve("GET", "/healthz", &body); code != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("got %d %v", code, body)
+	}
+}
+
+func TestServerReadyz(t *testing.T) {
+	e := newTestEnv(t, nil)
+	var body struct {
+		Ready     bool       `json:"ready"`
+		CheckedAt *time.Time `json:"checkedAt"`
+		Error     string     `json:"error"`
+	}
+	if code := e.serve("GET", "/readyz", &body); code != http.StatusServiceUnavailable || body.Ready || body.CheckedAt != nil || body.Error == "" {
+		t.Errorf("before the first check: got %d %+v", code, body)
+	}
+
+	if _, _, err := e.app.checkImmich(); err != nil {
+		t.Fatal(err)
+	}
+	body.Error = ""
+	if code := e.serve("GET", "/readyz", &body); code != http.StatusOK || !body.Ready || body.CheckedAt == nil || body.Error != "" {
+		t.Errorf("after a successful check: got %d %+v", code, body)
+	}
+
+	e.immich.FailNext("GET /api/users/me", 1, http.StatusUnauthorized)
+	e.app.checkImmich()
+	if code := e.serve("GET", "/readyz", &body); code != http.StatusServiceUnavailable || body.Ready || body.Error == "" {
+		t.Errorf("after a failed check: got %d %+v", code, body)
+	}
+}
+
+// statusBody is the /status response
+type statusBody struct {
+	Albums []struct {
+		ID         string     `json:"id"`
+		URL        string     `json:"url"`
+		Title      string     `json:"title"`
+		Running    bool       `json:"running"`
+		NextRun    *time.Time `json:"nextRun"`
+		LastRun    *time.Time `json:"lastRun"`
+		LastResult string     `json:"lastResult"`
+		Total      int        `json:"total"`
+		Added      int        `json:"added"`
+		Failed     int        `json:"failed"`
+	} `json:"albums"`
+}
+
+func TestServerStatus(t *testing.T) {
+	e := newTestEnv(t, nil)
+	var body statusBody
+	if code := e.serve("GET", "/status", &body); code != http.StatusOK || len(body.Albums) != 1 {
+		t.Fatalf("got %d %+v", code, body)
+	}
+	if st := body.Albums[0]; st.ID != "testalbum" || st.URL != testAlbumURL || st.LastRun != nil || st.NextRun != nil {
+		t.Errorf("before the first sync: got %+v", st)
+	}
+
+	e.src.add(3)
+	e.src.setFail("item001", true)
+	e.sync()
+	next := time.Now().Add(time.Hour).Truncate(time.Second)
+	e.app.Scheduler = scheduler.New(1, func(string) time.Time { return next })
+	e.app.Scheduler.Add(testAlbumURL, next)
+
+	body = statusBody{}
+	e.serve("GET", "/status", &body)
+	st := body.Albums[0]
+	if st.Title != "Holiday" || st.LastResult != "partial" || st.Total != 3 || st.Added != 2 || st.Failed != 1 || st.LastRun == nil {
+		t.Errorf("after a sync: got %+v", st)
+	}
+	if st.Running || st.NextRun == nil || !st.NextRun.Equal(next) {
+		t.Errorf("got next run %v (running %v), want %v", st.NextRun, st.Running, next)
+	}
+}
+
+func TestServerSync(t *testing.T) {
+	e := newTestEnv(t, nil)
+	e.src.add(1)
+	e.sync() // records the title
+
+	var body map[string]string
+	if code := e.serve("POST", "/sync/testalbum", &body); code != http.StatusConflict {
+		t.Errorf("without a scheduler: got %d %v", code, body)
+	}
+
+	later := time.Now().Add(24 * time.Hour)
+	e.app.Scheduler = scheduler.New(1, func(string) time.Time { return later })
+	tests := []struct {
+		name string
+		ref  string
+		code int
+	}{
+		{"id", "testalbum", http.StatusAccepted},
+		{"encoded url", url.PathEscape(testAlbumURL), http.StatusAccepted},
+		{"title", "holiday", http.StatusAccepted},
+		{"unknown", "nope", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e.app.Scheduler.Add(testAlbumURL, later)
+			body := map[string]string{}
+			code := e.serve("POST", "/sync/"+tt.ref, &body)
+			if code != tt.code {
+				t.Fatalf("got %d %v, want %d", code, body, tt.code)
+			}
+			if tt.code != http.StatusAccepted {
+				return
+			}
+			if body["id"] != "testalbum" || body["url"] != testAlbumURL || body["status"] != "queued" {
+				t.Errorf("got %v", body)
+			}
+			if next := e.app.Schedule()[0].Next; next.After(time.Now()) {
+				t.Errorf("album still scheduled at %v", next)
+			}
+		})
+	}
+
+	e.app.Scheduler.Remove(testAlbumURL)
+	if code := e.serve("POST", "/sync/testalbum", &body); code != http.StatusConflict {
+		t.Errorf("unscheduled album: got %d %v", code, body)
+	}
+}
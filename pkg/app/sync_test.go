@@ -0,0 +1,497 @@
+package app
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/config"
+	"warreth.dev/immich-sync/pkg/immich/immichtest"
+	"warreth.dev/immich-sync/pkg/report"
+	"warreth.dev/immich-sync/pkg/source"
+)
+
+const testAlbumURL = "https://photos.app.goo.gl/testalbum"
+
+// fakeSource serves one album from memory
+type fakeSource struct {
+	mu        sync.Mutex
+	title     string
+	items     []source.Item
+	data      map[string][]byte
+	fail      map[string]bool // item IDs whose download fails
+	downloads int
+}
+
+func (s *fakeSource) FetchAlbum(albumURL string) (*source.Album, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &source.Album{ID: "testalbum", Title: s.title, Items: append([]source.Item(nil), s.items...)}, nil
+}
+
+func (s *fakeSource) Download(item source.Item, opts source.DownloadOptions) (*source.Media, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.downloads++
+	if s.fail[item.ID] {
+		return nil, errors.New("injected download failure")
+	}
+	data := s.data[item.ID]
+	m := &source.Media{Body: io.NopCloser(strings.NewReader(string(data))), Size: int64(len(data)), Ext: ".jpg"}
+	if opts.Spool {
+		m.Checksum = sha1Hex(data)
+	}
+	return m, nil
+}
+
+// add appends n items with distinct content
+func (s *fakeSource) add(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	base := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("item%03d", len(s.items))
+		s.items = append(s.items, source.Item{ID: id, URL: "https://example.com/" + id, TakenAt: base.Add(time.Duration(len(s.items)) * time.Minute)})
+		s.data[id] = []byte("jpeg-" + id)
+	}
+}
+
+func (s *fakeSource) setData(id string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[id] = data
+}
+
+func (s *fakeSource) setFail(id string, fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail[id] = fail
+}
+
+func (s *fakeSource) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, it := range s.items {
+		if it.ID == id {
+			s.items = append(s.items[:i], s.items[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *fakeSource) downloadCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downloads
+}
+
+func sha1Hex(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// testEnv is an App syncing one fake album into a fake Immich
+type testEnv struct {
+	t      *testing.T
+	immich *immichtest.Server
+	src    *fakeSource
+	app    *App
+}
+
+// newTestEnv loads a config with one album through config.Load, so defaults and validation
+// apply, lets configure adjust it and starts the app with the fake source
+func newTestEnv(t *testing.T, configure func(*config.Config)) *testEnv {
+	t.Helper()
+	srv := immichtest.NewServer()
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	cfgJSON := fmt.Sprintf(`{"apiKey": %q, "apiURL": %q, "stateDir": %q, "albums": [{"url": %q}]}`,
+		immichtest.APIKey, srv.APIURL(), filepath.Join(dir, "state"), testAlbumURL)
+	if err := os.WriteFile(path, []byte(cfgJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	src := &fakeSource{title: "Holiday", data: make(map[string][]byte), fail: make(map[string]bool)}
+	a.Sources[config.DefaultSource] = src
+	return &testEnv{t: t, immich: srv, src: src, app: a}
+}
+
+// sync runs "once" and returns the result of the album
+func (e *testEnv) sync() SyncResult {
+	e.t.Helper()
+	results, err := e.app.RunOnce()
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if len(results) != 1 {
+		e.t.Fatalf("got %d album results", len(results))
+	}
+	return results[0].Result
+}
+
+// album returns the only Immich album
+func (e *testEnv) album() immichtest.Album {
+	e.t.Helper()
+	albums := e.immich.Albums()
+	if len(albums) != 1 {
+		e.t.Fatalf("got %d Immich albums, want 1", len(albums))
+	}
+	return albums[0]
+}
+
+// asset returns the Immich asset of a source item, found through the state store
+func (e *testEnv) asset(itemID string) immichtest.Asset {
+	e.t.Helper()
+	rec, ok := e.app.State.Get(testAlbumURL, itemID)
+	if !ok {
+		e.t.Fatalf("no state record for %s", itemID)
+	}
+	for _, a := range e.immich.Assets() {
+		if a.Id == rec.AssetID {
+			return a
+		}
+	}
+	e.t.Fatalf("asset %s of %s not in Immich", rec.AssetID, itemID)
+	return immichtest.Asset{}
+}
+
+// outcomes maps item IDs to their report outcome
+func outcomes(res SyncResult) map[string]string {
+	out := make(map[string]string, len(res.Items))
+	for _, item := range res.Items {
+		out[item.ItemID] = item.Outcome
+	}
+	return out
+}
+
+const (
+	routeUpload     = "POST /api/assets"
+	routeAddToAlbum = "PUT /api/albums/{id}/assets"
+	routeNewAlbum   = "POST /api/albums"
+)
+
+func TestSyncUploadsThenSkips(t *testing.T) {
+	e := newTestEnv(t, nil)
+	e.src.add(5)
+
+	res := e.sync()
+	if res.Err != nil || res.Total != 5 || res.Added != 5 || res.Failed != 0 {
+		t.Fatalf("first run: %+v", res)
+	}
+	album := e.album()
+	if album.AlbumName != "Holiday" || len(album.AssetIds) != 5 {
+		t.Errorf("album %q has %d assets", album.AlbumName, len(album.AssetIds))
+	}
+	a := e.asset("item002")
+	if a.OriginalFileName != "gp_item002.jpg" || !a.FileCreatedAt.Equal(time.Date(2024, 6, 1, 10, 2, 0, 0, time.UTC)) {
+		t.Errorf("asset %+v", a)
+	}
+	if !strings.Contains(a.Description, "Source Album: Holiday ("+testAlbumURL+")") {
+		t.Errorf("description %q", a.Description)
+	}
+
+	res = e.sync()
+	if res.Err != nil || res.Added != 0 || res.Skipped != 5 {
+		t.Fatalf("second run: %+v", res)
+	}
+	for id, outcome := range outcomes(res) {
+		if outcome != report.SkippedExisting {
+			t.Errorf("%s: %s", id, outcome)
+		}
+	}
+	if got := e.immich.Calls(routeUpload); got != 5 {
+		t.Errorf("%d uploads, want 5", got)
+	}
+	if got := e.src.downloadCount(); got != 5 {
+		t.Errorf("%d downloads, want 5", got)
+	}
+	if got := e.immich.Calls(routeNewAlbum); got != 1 {
+		t.Errorf("album created %d times", got)
+	}
+
+	// New items in the source are the only ones uploaded by the next run
+	e.src.add(2)
+	res = e.sync()
+	if res.Added != 2 || res.Skipped != 5 || len(e.album().AssetIds) != 7 {
+		t.Errorf("third run: %+v", res)
+	}
+}
+
+func TestSyncAlbumResolution(t *testing.T) {
+	t.Run("existing album by title", func(t *testing.T) {
+		e := newTestEnv(t, nil)
+		e.src.add(2)
+		id := e.immich.AddAlbum("Holiday")
+		e.sync()
+		if got := e.immich.Calls(routeNewAlbum); got != 0 {
+			t.Errorf("album created %d times", got)
+		}
+		if album := e.album(); album.Id != id || len(album.AssetIds) != 2 {
+			t.Errorf("album %+v", album)
+		}
+	})
+
+	t.Run("albumName", func(t *testing.T) {
+		e := newTestEnv(t, func(c *config.Config) { c.Albums[0].AlbumName = "Renamed" })
+		e.src.add(2)
+		res := e.sync()
+		if album := e.album(); album.AlbumName != "Renamed" || res.Title != "Renamed" {
+			t.Errorf("album %q, title %q", album.AlbumName, res.Title)
+		}
+	})
+
+	t.Run("immichAlbumId", func(t *testing.T) {
+		e := newTestEnv(t, nil)
+		id := e.immich.AddAlbum("Something else")
+		e.app.Cfg.Albums[0].ImmichAlbumID = id
+		e.src.add(2)
+		e.sync()
+		if album := e.album(); album.Id != id || len(album.AssetIds) != 2 {
+			t.Errorf("album %+v", album)
+		}
+	})
+
+	t.Run("album creation fails", func(t *testing.T) {
+		e := newTestEnv(t, nil)
+		e.src.add(3)
+		e.immich.FailNext(routeNewAlbum, 1, 500)
+		res := e.sync()
+		if res.Status() != "failed" || res.Total != 0 {
+			t.Errorf("result %+v", res)
+		}
+		// Nothing is uploaded that could not be added to the album and recorded
+		if e.immich.Calls(routeUpload) != 0 || e.src.downloadCount() != 0 {
+			t.Errorf("%d uploads, %d downloads", e.immich.Calls(routeUpload), e.src.downloadCount())
+		}
+
+		res = e.sync()
+		if res.Status() != "success" || res.Added != 3 || len(e.album().AssetIds) != 3 {
+			t.Errorf("second run: %+v", res)
+		}
+	})
+
+	t.Run("empty album", func(t *testing.T) {
+		e := newTestEnv(t, nil)
+		if res := e.sync(); res.Err != nil || res.Total != 0 {
+			t.Errorf("result %+v", res)
+		}
+		if len(e.immich.Albums()) != 0 {
+			t.Error("album created for an empty source album")
+		}
+	})
+}
+
+func TestSyncIncrementalFlush(t *testing.T) {
+	e := newTestEnv(t, nil)
+	e.src.add(20)
+
+	// Every tenth of the album is added as it completes; a failed batch is retried with the next one
+	e.immich.FailNext(routeAddToAlbum, 1, 500)
+	res := e.sync()
+	if res.Added != 20 {
+		t.Fatalf("result %+v", res)
+	}
+	if got := e.immich.Calls(routeAddToAlbum); got != 10 {
+		t.Errorf("%d album updates, want 10", got)
+	}
+	if got := len(e.album().AssetIds); got != 20 {
+		t.Errorf("album has %d assets, want 20", got)
+	}
+	for i := 0; i < 20; i++ {
+		if _, ok := e.app.State.Get(testAlbumURL, fmt.Sprintf("item%03d", i)); !ok {
+			t.Errorf("item%03d not recorded", i)
+		}
+	}
+}
+
+func TestSyncFailedItems(t *testing.T) {
+	e := newTestEnv(t, nil)
+	e.src.add(3)
+	e.src.setFail("item001", true)
+
+	res := e.sync()
+	if res.Added != 2 || res.Failed != 1 || res.Status() != "partial" {
+		t.Fatalf("result %+v", res)
+	}
+	if _, ok := e.app.State.Get(testAlbumURL, "item001"); ok {
+		t.Error("failed item recorded in the state store")
+	}
+
+	e.src.setFail("item001", false)
+	res = e.sync()
+	if res.Added != 1 || res.Skipped != 2 || len(e.album().AssetIds) != 3 {
+		t.Errorf("second run: %+v", res)
+	}
+}
+
+func TestSyncChecksumDedup(t *testing.T) {
+	e := newTestEnv(t, func(c *config.Config) { c.ChecksumDedup = true })
+	e.src.add(3)
+	// item001 was already backed up from a phone under another name
+	phoneId := e.immich.AddAsset(immichtest.Asset{OriginalFileName: "IMG_0001.jpg", Checksum: sha1Hex([]byte("jpeg-item001"))})
+
+	res := e.sync()
+	if res.Added != 2 || res.Linked != 1 {
+		t.Fatalf("result %+v", res)
+	}
+	if outcomes(res)["item001"] != report.Linked {
+		t.Errorf("item001: %s", outcomes(res)["item001"])
+	}
+	if got := len(e.immich.Assets()); got != 3 {
+		t.Errorf("%d assets in Immich, want 3", got)
+	}
+	if got := e.asset("item001"); got.Id != phoneId {
+		t.Errorf("item001 recorded as %s, want %s", got.Id, phoneId)
+	}
+	if album := e.album(); len(album.AssetIds) != 3 {
+		t.Errorf("album has %d assets", len(album.AssetIds))
+	}
+
+	res = e.sync()
+	if res.Skipped != 3 || e.immich.Calls(routeUpload) != 2 {
+		t.Errorf("second run: %+v, %d uploads", res, e.immich.Calls(routeUpload))
+	}
+}
+
+func TestSyncTrashPolicy(t *testing.T) {
+	tests := []struct {
+		policy      string
+		changeData  bool   // the source file changed, so a new upload is not deduplicated
+		outcome     string // of the trashed item in the second run
+		trashed     bool   // whether the original asset is still trashed afterwards
+		deleted     bool   // whether the original asset was deleted permanently
+		assets      int    // assets in Immich afterwards
+		albumAssets int    // visible assets in the album afterwards
+	}{
+		{policy: config.TrashRespect, outcome: report.SkippedExisting, trashed: true, assets: 3, albumAssets: 2},
+		{policy: config.TrashRestore, outcome: report.Restored, trashed: false, assets: 3, albumAssets: 3},
+		// The trashed copy is deleted, so Immich cannot deduplicate the new upload against it
+		{policy: config.TrashReupload, outcome: report.Added, deleted: true, assets: 3, albumAssets: 3},
+		{policy: config.TrashReupload, changeData: true, outcome: report.Added, deleted: true, assets: 3, albumAssets: 3},
+	}
+	for _, tt := range tests {
+		name := tt.policy
+		if tt.changeData {
+			name += " changed"
+		}
+		t.Run(name, func(t *testing.T) {
+			e := newTestEnv(t, func(c *config.Config) { c.TrashPolicy = tt.policy })
+			e.src.add(3)
+			e.sync()
+			trashedId := e.asset("item001").Id
+			e.immich.Trash(trashedId)
+			if tt.changeData {
+				e.src.setData("item001", []byte("edited"))
+			}
+
+			res := e.sync()
+			if res.Err != nil || res.Failed != 0 {
+				t.Fatalf("result %+v", res)
+			}
+			if got := outcomes(res)["item001"]; got != tt.outcome {
+				t.Errorf("outcome %s, want %s", got, tt.outcome)
+			}
+			found := false
+			for _, a := range e.immich.Assets() {
+				if a.Id == trashedId {
+					found = true
+					if a.IsTrashed != tt.trashed {
+						t.Errorf("trashed = %v, want %v", a.IsTrashed, tt.trashed)
+					}
+				}
+			}
+			if found == tt.deleted {
+				t.Errorf("deleted = %v, want %v", !found, tt.deleted)
+			}
+			if got := len(e.immich.Assets()); got != tt.assets {
+				t.Errorf("%d assets, want %d", got, tt.assets)
+			}
+			visible := 0
+			for _, a := range e.immich.Assets() {
+				for _, id := range e.album().AssetIds {
+					if a.Id == id && !a.IsTrashed {
+						visible++
+					}
+				}
+			}
+			if visible != tt.albumAssets {
+				t.Errorf("%d visible album assets, want %d", visible, tt.albumAssets)
+			}
+
+			// The outcome sticks: a third run changes nothing
+			uploads := e.immich.Calls(routeUpload)
+			res = e.sync()
+			if res.Skipped != 3 || e.immich.Calls(routeUpload) != uploads {
+				t.Errorf("third run: %+v", res)
+			}
+		})
+	}
+}
+
+func TestSyncTrashRespectWithoutState(t *testing.T) {
+	// Trashed assets are found through the trash even when the state store was lost
+	e := newTestEnv(t, nil)
+	e.src.add(2)
+	e.sync()
+	trashedId := e.asset("item000").Id
+	e.immich.Trash(trashedId)
+	for _, id := range []string{"item000", "item001"} {
+		e.app.State.Delete(testAlbumURL, id)
+	}
+
+	res := e.sync()
+	if got := outcomes(res)["item000"]; got != report.SkippedTrashed {
+		t.Errorf("outcome %s, want %s", got, report.SkippedTrashed)
+	}
+	if e.immich.Calls(routeUpload) != 2 {
+		t.Errorf("%d uploads, want 2", e.immich.Calls(routeUpload))
+	}
+
+	// Later runs remember why the item is not in the album
+	if got := outcomes(e.sync())["item000"]; got != report.SkippedTrashed {
+		t.Errorf("later outcome %s, want %s", got, report.SkippedTrashed)
+	}
+}
+
+func TestSyncTrashReuploadForeignAsset(t *testing.T) {
+	// A trashed phone backup with the same content is restored, never deleted
+	e := newTestEnv(t, func(c *config.Config) {
+		c.ChecksumDedup = true
+		c.TrashPolicy = config.TrashReupload
+	})
+	e.src.add(1)
+	phoneId := e.immich.AddAsset(immichtest.Asset{OriginalFileName: "IMG_0001.jpg", Checksum: sha1Hex([]byte("jpeg-item000"))})
+	e.immich.Trash(phoneId)
+
+	res := e.sync()
+	if got := outcomes(res)["item000"]; got != report.Restored {
+		t.Errorf("outcome %s, want %s", got, report.Restored)
+	}
+	assets := e.immich.Assets()
+	if len(assets) != 1 || assets[0].Id != phoneId || assets[0].IsTrashed {
+		t.Errorf("assets %+v, want the restored phone asset only", assets)
+	}
+}
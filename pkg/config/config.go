@@ -1,19 +1,77 @@
 package config
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"os"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/cron"
+)
+
+// ErrNoConfig is returned by ReadConfig when there is neither a config file nor any config environment variable
+var ErrNoConfig = errors.New("config file not found and ENV vars missing")
+
+// DefaultStateDir is where sync state is persisted when "stateDir" is not set
+const DefaultStateDir = "state"
+
+// Deletion policies for items that disappear from the source album
+const (
+	DeletionKeep   = "keep"   // leave the asset in the Immich album
+	DeletionRemove = "remove" // remove the asset from the Immich album
+	DeletionTrash  = "trash"  // remove from the album and trash the asset if no other album references it
+)
+
+// Trash policies for synced assets the user moved to the Immich trash
+const (
+	TrashRespect  = "respect"  // leave them trashed, never re-link or re-upload
+	TrashRestore  = "restore"  // restore them from the trash and re-add them to the album
+	TrashReupload = "reupload" // upload a fresh copy
+)
+
+// Metadata writing modes
+const (
+	MetadataEmbed   = "embed"   // rewrite JPEGs, XMP sidecar for everything else
+	MetadataSidecar = "sidecar" // always upload an XMP sidecar
 )
 
-type GooglePhotosConfig struct {
+// DefaultDeletionThreshold aborts deletion mirroring when more than this fraction of an album would be removed
+const DefaultDeletionThreshold = 0.25
+
+// DefaultSyncInterval is used for albums without syncInterval or schedule
+const DefaultSyncInterval = 24 * time.Hour
+
+// DefaultKeepReports is the number of reports kept per album when "keepReports" is not set
+const DefaultKeepReports = 30
+
+// Retry defaults for failed items
+const (
+	DefaultRetryAttempts = 5
+	DefaultRetryBackoff  = 5 * time.Minute
+)
+
+// DefaultSource is the provider used when an album entry does not declare one
+const DefaultSource = "googlephotos"
+
+// AlbumConfig describes a single shared album to mirror into Immich
+type AlbumConfig struct {
+	Source        string `json:"source"` // Optional, provider type (default "googlephotos")
 	URL           string `json:"url"`
-	ImmichAlbumID string `json:"immichAlbumId"`     // Optional, if existing
-	AlbumName     string `json:"albumName"`         // Optional, to create new
-	SyncInterval  string `json:"syncInterval"`      // e.g., "12h", "60m"
+	ImmichAlbumID string `json:"immichAlbumId"` // Optional, if existing
+	AlbumName     string `json:"albumName"`     // Optional, to create new
+	SyncInterval  string `json:"syncInterval"`  // e.g., "12h", "60m"
+	Schedule      string `json:"schedule"`      // Optional, cron expression or descriptor (e.g. "0 3 * * *", "@daily"), replaces syncInterval
+	Timezone      string `json:"timezone"`      // Optional, IANA zone for schedule (default local time)
+
+	DeletionPolicy    string   `json:"deletionPolicy"`    // Optional, "keep" (default), "remove" or "trash"
+	DeletionThreshold *float64 `json:"deletionThreshold"` // Optional, max fraction of album items removed per run (default 0.25, 0 refuses any removal)
+
+	Notify []string `json:"notify"` // Optional, names of the notifications this album sends
 }
 
+// GooglePhotosConfig is the album entry type of the "googlePhotos" list
+type GooglePhotosConfig = AlbumConfig
+
 type Config struct {
 	ApiKey         string               `json:"apiKey"`
 	ApiURL         string               `json:"apiURL"`
@@ -22,42 +80,178 @@ type Config struct {
 	AlbumWorkers   int                  `json:"albumWorkers"`   // Optional, concurrent album processing (default 1)
 	StrictMetadata bool                 `json:"strictMetadata"` // Optional, skip items with missing dates
 	SkipVideos     bool                 `json:"skipVideos"`     // Optional, skip video items entirely
-	GooglePhotos   []GooglePhotosConfig `json:"googlePhotos"`
+	StateDir       string               `json:"stateDir"`       // Optional, directory for persistent sync state (default "state")
+	SpoolDir       string               `json:"spoolDir"`       // Optional, temp directory for buffering downloads (default system temp dir)
+	ChecksumDedup  bool                 `json:"checksumDedup"`  // Optional, link items whose content already exists in Immich instead of uploading
+	MotionPhotos   bool                 `json:"motionPhotos"`   // Optional, split motion photos into still + linked live-photo video
+	WriteMetadata  string               `json:"writeMetadata"`  // Optional, "embed" (EXIF/XMP into JPEGs, sidecar otherwise) or "sidecar"
+	HTTPAddr       string               `json:"httpAddr"`       // Optional, listen address of the status server, e.g. ":8080" (disabled by default)
+	TrashPolicy    string               `json:"trashPolicy"`    // Optional, "respect" (default), "restore" or "reupload"
+	ConfDir        string               `json:"confDir"`        // Optional, directory of album fragment files, relative to the config file (default "conf.d")
+	Notifications  []NotificationConfig `json:"notifications"`  // Optional, notification channels albums can opt into
+	ReportsDir     string               `json:"reportsDir"`     // Optional, directory for a JSON and CSV report of every album sync (disabled by default)
+	KeepReports    int                  `json:"keepReports"`    // Optional, reports kept per album (default 30)
+	RetryAttempts  int                  `json:"retryAttempts"`  // Optional, attempts before a failed item is dead-lettered (default 5)
+	RetryBackoff   string               `json:"retryBackoff"`   // Optional, delay before the first retry of a failed item, doubled per attempt (default "5m")
+	GooglePhotos   []GooglePhotosConfig `json:"googlePhotos"`   // Google Photos albums (source is implied)
+	Albums         []AlbumConfig        `json:"albums"`         // Albums from any source, each declaring its "source"
+
+	Warnings []string `json:"-"` // non-fatal problems found by ReadConfig
 }
 
-func ReadConfig(path string) (*Config, error) {
-	file, err := os.Open(path)
+// AllAlbums returns every configured album, googlePhotos entries first
+func (c *Config) AllAlbums() []AlbumConfig {
+	all := make([]AlbumConfig, 0, len(c.GooglePhotos)+len(c.Albums))
+	all = append(all, c.GooglePhotos...)
+	all = append(all, c.Albums...)
+	return all
+}
+
+// applyDefaults fills in optional fields that have a non-zero default
+func (c *Config) applyDefaults() {
+	if c.TrashPolicy == "" {
+		c.TrashPolicy = TrashRespect
+	}
+	if c.RetryAttempts == 0 {
+		c.RetryAttempts = DefaultRetryAttempts
+	}
+	if c.KeepReports == 0 {
+		c.KeepReports = DefaultKeepReports
+	}
+	for i := range c.Notifications {
+		c.Notifications[i].applyDefaults()
+	}
+	for i := range c.GooglePhotos {
+		c.GooglePhotos[i].applyDefaults()
+	}
+	for i := range c.Albums {
+		c.Albums[i].applyDefaults()
+	}
+}
+
+// ParseRetryBackoff returns the delay before the first retry of a failed item
+func (c *Config) ParseRetryBackoff() (time.Duration, error) {
+	if c.RetryBackoff == "" {
+		return DefaultRetryBackoff, nil
+	}
+	d, err := time.ParseDuration(c.RetryBackoff)
 	if err != nil {
-		if os.IsNotExist(err) {
-			// Try ENV vars for basic config
-			apiKey := os.Getenv("IMMICH_API_KEY")
-			apiURL := os.Getenv("IMMICH_API_URL")
-			
-			if apiKey == "" || apiURL == "" {
-				return nil, fmt.Errorf("config file not found and ENV vars missing")
+		return 0, fmt.Errorf("invalid retryBackoff %q: %w", c.RetryBackoff, err)
+	}
+	if d < MinRetryBackoff {
+		return 0, fmt.Errorf("retryBackoff must be at least %s, got %q", MinRetryBackoff, c.RetryBackoff)
+	}
+	return d, nil
+}
+
+// MaxDeletionFraction returns the "deletionThreshold", or DefaultDeletionThreshold if unset
+func (ac AlbumConfig) MaxDeletionFraction() float64 {
+	if ac.DeletionThreshold == nil {
+		return DefaultDeletionThreshold
+	}
+	return *ac.DeletionThreshold
+}
+
+// ParseSchedule returns when the album syncs: the cron "schedule" evaluated in "timezone"
+// if set, otherwise every "syncInterval" (default 24h).
+func (ac AlbumConfig) ParseSchedule() (cron.Schedule, error) {
+	if ac.Schedule != "" {
+		if ac.SyncInterval != "" {
+			return nil, fmt.Errorf("set either schedule or syncInterval, not both")
+		}
+		loc := time.Local
+		if ac.Timezone != "" {
+			var err error
+			if loc, err = time.LoadLocation(ac.Timezone); err != nil {
+				return nil, fmt.Errorf("invalid timezone %q: %w", ac.Timezone, err)
 			}
-			
-			return &Config{
-				ApiKey: apiKey,
-				ApiURL: apiURL,
-			}, nil
 		}
-		return nil, err
+		expr, err := cron.Parse(ac.Schedule, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule: %w", err)
+		}
+		if expr.Next(time.Now()).IsZero() {
+			return nil, fmt.Errorf("schedule %q never fires", ac.Schedule)
+		}
+		return expr, nil
+	}
+	if ac.Timezone != "" {
+		return nil, fmt.Errorf("timezone is only used together with schedule")
 	}
-	defer file.Close()
-	
-	bytefile, err := io.ReadAll(file)
+	if ac.SyncInterval == "" {
+		return cron.Every(DefaultSyncInterval), nil
+	}
+	interval, err := time.ParseDuration(ac.SyncInterval)
 	if err != nil {
-		return nil, fmt.Errorf("error reading config: %w", err)
+		return nil, fmt.Errorf("invalid syncInterval %q: %w", ac.SyncInterval, err)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("syncInterval must be positive, got %q", ac.SyncInterval)
 	}
+	return cron.Every(interval), nil
+}
+
+func (ac *AlbumConfig) applyDefaults() {
+	if ac.Source == "" {
+		ac.Source = DefaultSource
+	}
+	if ac.DeletionPolicy == "" {
+		ac.DeletionPolicy = DeletionKeep
+	}
+}
+
+// ReadConfig loads the config file at path as JSON, YAML or TOML (by extension), applies
+// environment variable overrides, merges the albums of its conf.d fragments and validates the result.
+func ReadConfig(path string) (*Config, error) {
+	return Load(path, nil)
+}
+
+// Load is ReadConfig with overrides from command-line flags, keyed by top-level JSON key.
+// Precedence is flags, then environment variables, then the config file.
+func Load(path string, overrides map[string]string) (*Config, error) {
 	var config Config
-	if err := json.Unmarshal(bytefile, &config); err != nil {
+	var problems []Problem
+
+	bytefile, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading config: %w", err)
+		}
+		// No file: configure from environment variables and flags only
+		if !hasEnv() && len(overrides) == 0 {
+			return nil, ErrNoConfig
+		}
+	} else {
+		if bytefile, err = toJSON(path, bytefile); err != nil {
+			return nil, err
+		}
+		if problems, err = decodeStrict(bytefile, &config); err != nil {
+			return nil, err
+		}
+	}
+
+	v := &validator{problems: problems}
+	applyEnv(v, &config)
+	applyOverrides(v, &config, overrides)
+	problems = v.problems
+
+	fragments, fragmentProblems, err := readFragments(path, ConfDir(path, config.ConfDir))
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, fragmentProblems...)
+
+	warnings, err := config.validate(problems, fragments)
+	if err != nil {
 		return nil, err
 	}
-	
-	// Override/Fallback with ENV
-	if config.ApiKey == "" { config.ApiKey = os.Getenv("IMMICH_API_KEY") }
-	if config.ApiURL == "" { config.ApiURL = os.Getenv("IMMICH_API_URL") }
-	
+	config.Warnings = warnings
+
+	for _, f := range fragments {
+		config.GooglePhotos = append(config.GooglePhotos, f.GooglePhotos...)
+		config.Albums = append(config.Albums, f.Albums...)
+	}
+	config.applyDefaults()
+
 	return &config, nil
 }
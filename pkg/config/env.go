@@ -0,0 +1,258 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EnvPrefix prefixes the environment variable of every config key, e.g. IMMICH_SYNC_ALBUM_WORKERS for "albumWorkers".
+// Every variable can also be read from a file named by the same variable with a "_FILE" suffix (Docker secrets).
+const EnvPrefix = "IMMICH_SYNC_"
+
+// legacyEnv maps the variables supported before EnvPrefix existed to their keys. The prefixed variable wins.
+var legacyEnv = map[string]string{
+	"IMMICH_API_KEY": "apiKey",
+	"IMMICH_API_URL": "apiURL",
+}
+
+// EnvName returns the environment variable for a top-level config key: "apiURL" -> "IMMICH_SYNC_API_URL"
+func EnvName(key string) string {
+	return EnvPrefix + envKey(key)
+}
+
+// envKey converts a camelCase JSON key to SCREAMING_SNAKE_CASE, keeping acronyms together
+func envKey(key string) string {
+	var b strings.Builder
+	runes := []rune(key)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(runes[i-1]) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// lookupEnv returns the value of name, or the trimmed contents of the file named by name_FILE.
+// Empty values count as unset.
+func lookupEnv(v *validator, name string) (string, bool) {
+	value := os.Getenv(name)
+	file := os.Getenv(name + "_FILE")
+	switch {
+	case value != "" && file != "":
+		v.errorf(name, "both %s and %s_FILE are set", name, name)
+		return "", false
+	case value != "":
+		return value, true
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			v.errorf(name+"_FILE", "%v", err)
+			return "", false
+		}
+		return strings.TrimRight(string(data), "\r\n"), true
+	}
+	return "", false
+}
+
+// EnvConfigPath names the config file. It is read by the command, not a config key, so it does
+// not count as configuration from the environment.
+const EnvConfigPath = EnvPrefix + "CONFIG"
+
+// hasEnv reports whether any config environment variable is set. Other variables sharing
+// EnvPrefix, like EnvConfigPath, don't count.
+func hasEnv() bool {
+	for _, kv := range os.Environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		name = strings.TrimSuffix(name, "_FILE")
+		if isConfigEnv(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isConfigEnv reports whether applyEnv reads the variable name
+func isConfigEnv(name string) bool {
+	if legacyEnv[name] != "" || name == "IMMICH_READD_TRASHED_ITEMS" {
+		return true
+	}
+	for key, field := range jsonFields(reflect.TypeOf(Config{})) {
+		env := EnvName(key)
+		if name == env {
+			return true
+		}
+		// Indexed variables of album lists, e.g. IMMICH_SYNC_GOOGLE_PHOTOS_0_URL
+		if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct && strings.HasPrefix(name, env+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEnv overrides config fields with environment variables. Album lists are replaced by a
+// JSON array in e.g. IMMICH_SYNC_GOOGLE_PHOTOS, or edited per entry with indexed variables like
+// IMMICH_SYNC_GOOGLE_PHOTOS_0_URL; indexes past the end of the list add entries.
+func applyEnv(v *validator, config *Config) {
+	for name, key := range legacyEnv {
+		if os.Getenv(EnvName(key)) != "" || os.Getenv(EnvName(key)+"_FILE") != "" {
+			continue
+		}
+		if value, ok := lookupEnv(v, name); ok {
+			setKey(v, name, reflect.ValueOf(config).Elem(), key, value)
+		}
+	}
+	// IMMICH_READD_TRASHED_ITEMS=true predates trashPolicy
+	if value, ok := lookupEnv(v, "IMMICH_READD_TRASHED_ITEMS"); ok {
+		if readd, err := strconv.ParseBool(value); err != nil {
+			v.errorf("IMMICH_READD_TRASHED_ITEMS", "expected true or false, got %q", value)
+		} else if readd {
+			config.TrashPolicy = TrashReupload
+		}
+	}
+	applyEnvStruct(v, reflect.ValueOf(config).Elem(), EnvPrefix)
+}
+
+func applyEnvStruct(v *validator, target reflect.Value, prefix string) {
+	fields := jsonFields(target.Type())
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field := fields[key]
+		name := prefix + envKey(key)
+		fv := target.FieldByIndex(field.Index)
+
+		if fv.Kind() != reflect.Slice {
+			if value, ok := lookupEnv(v, name); ok {
+				setValue(v, name, fv, value)
+			}
+			continue
+		}
+
+		if value, ok := lookupEnv(v, name); ok {
+			list := reflect.New(fv.Type())
+			problems, err := decodeStrict([]byte(value), list.Interface())
+			if err != nil {
+				v.errorf(name, "%v", err)
+			}
+			for _, p := range problems {
+				v.errorf(name+joinIndexPath(p.Path), "%s", p.Message)
+			}
+			if err == nil && len(problems) == 0 {
+				fv.Set(list.Elem())
+			}
+		}
+
+		if fv.Type().Elem().Kind() != reflect.Struct {
+			continue // lists of scalars, like "notify", are only set as a whole
+		}
+		for _, i := range envIndexes(name + "_") {
+			elem := i
+			if elem >= fv.Len() {
+				// Indexes past the end append in index order, so gaps don't leave empty entries
+				elem = fv.Len()
+				fv.Set(reflect.Append(fv, reflect.Zero(fv.Type().Elem())))
+			}
+			applyEnvStruct(v, fv.Index(elem), fmt.Sprintf("%s_%d_", name, i))
+		}
+	}
+}
+
+// joinIndexPath appends a JSON path of a list element to a variable name for problem reports
+func joinIndexPath(path string) string {
+	if path == "" || strings.HasPrefix(path, "[") {
+		return path
+	}
+	return "." + path
+}
+
+// envIndexes returns the sorted list indexes used by variables starting with prefix, e.g. prefix "X_" and "X_2_URL" -> 2
+func envIndexes(prefix string) []int {
+	seen := make(map[int]bool)
+	for _, kv := range os.Environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		end := strings.IndexByte(rest, '_')
+		if end <= 0 {
+			continue
+		}
+		if i, err := strconv.Atoi(rest[:end]); err == nil && i >= 0 {
+			seen[i] = true
+		}
+	}
+	indexes := make([]int, 0, len(seen))
+	for i := range seen {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// applyOverrides sets top-level keys from command-line flags, the highest precedence
+func applyOverrides(v *validator, config *Config, overrides map[string]string) {
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		setKey(v, "flag "+key, reflect.ValueOf(config).Elem(), key, overrides[key])
+	}
+}
+
+// setKey sets the top-level field with the given JSON key from its string form
+func setKey(v *validator, source string, target reflect.Value, key, value string) {
+	field, ok := jsonFields(target.Type())[key]
+	if !ok {
+		v.errorf(source, "unknown config key %q", key)
+		return
+	}
+	setValue(v, source, target.FieldByIndex(field.Index), value)
+}
+
+// setValue parses value into a scalar field, reporting parse errors under source
+func setValue(v *validator, source string, fv reflect.Value, value string) {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			v.errorf(source, "expected true or false, got %q", value)
+			return
+		}
+		fv.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			v.errorf(source, "expected an integer, got %q", value)
+			return
+		}
+		fv.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			v.errorf(source, "expected a number, got %q", value)
+			return
+		}
+		fv.SetFloat(f)
+	case reflect.Ptr:
+		// Optional fields, like deletionThreshold, where the zero value is not "unset"
+		elem := reflect.New(fv.Type().Elem())
+		setValue(v, source, elem.Elem(), value)
+		fv.Set(elem)
+	default:
+		v.errorf(source, "cannot be set from a string")
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets every variable the config could read, for the duration of the test
+func clearEnv(t *testing.T) {
+	for _, kv := range os.Environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if strings.HasPrefix(name, EnvPrefix) || strings.HasPrefix(name, "IMMICH_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestHasEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{EnvConfigPath, false},
+		{EnvPrefix + "VERSION", false},
+		{EnvPrefix + "CONFIG_FILE", false},
+		{"IMMICH_SYNC_API_KEY", true},
+		{"IMMICH_SYNC_API_KEY_FILE", true},
+		{"IMMICH_SYNC_ALBUM_WORKERS", true},
+		{"IMMICH_SYNC_GOOGLE_PHOTOS", true},
+		{"IMMICH_SYNC_GOOGLE_PHOTOS_0_URL", true},
+		{"IMMICH_SYNC_NOTIFICATIONS_1_URL", true},
+		{"IMMICH_SYNC_GOOGLE_PHOTOS_0_NOTIFY", true},
+		{"IMMICH_API_URL", true},
+		{"IMMICH_READD_TRASHED_ITEMS", true},
+		{"IMMICH_VERSION", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv(tt.name, "x")
+			if got := hasEnv(); got != tt.want {
+				t.Errorf("hasEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadNoConfig(t *testing.T) {
+	clearEnv(t)
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv(EnvConfigPath, path)
+
+	if _, err := Load(path, nil); !errors.Is(err, ErrNoConfig) {
+		t.Fatalf("Load with only %s set: %v, want ErrNoConfig", EnvConfigPath, err)
+	}
+
+	t.Setenv("IMMICH_SYNC_API_KEY", "key")
+	if _, err := Load(path, nil); err == nil || errors.Is(err, ErrNoConfig) {
+		t.Fatalf("Load with an incomplete config from the environment: %v, want validation errors", err)
+	}
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfDir is the fragment directory next to the config file used when "confDir" is not set
+const DefaultConfDir = "conf.d"
+
+// fragment is a conf.d file holding additional album definitions
+type fragment struct {
+	name string // path relative to the config file, used in problem paths
+
+	GooglePhotos []GooglePhotosConfig `json:"googlePhotos"`
+	Albums       []AlbumConfig        `json:"albums"`
+}
+
+// IsConfigFile reports whether path has an extension ReadConfig understands
+func IsConfigFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml", ".toml":
+		return true
+	}
+	return false
+}
+
+// toJSON converts a YAML or TOML document to JSON, chosen by the file extension, so that all
+// formats share the JSON decoding and validation. Other extensions are treated as JSON.
+func toJSON(path string, data []byte) ([]byte, error) {
+	var doc interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		if doc == nil {
+			doc = map[string]interface{}{}
+		}
+	case ".toml":
+		var table map[string]interface{}
+		if err := toml.Unmarshal(data, &table); err != nil {
+			return nil, fmt.Errorf("invalid TOML: %w", err)
+		}
+		doc = table
+	default:
+		return data, nil
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported value in %s: %w", filepath.Base(path), err)
+	}
+	return out, nil
+}
+
+// ConfDir returns the fragment directory of the config file at path
+func ConfDir(path string, confDir string) string {
+	if confDir == "" {
+		confDir = DefaultConfDir
+	}
+	if filepath.IsAbs(confDir) {
+		return confDir
+	}
+	return filepath.Join(filepath.Dir(path), confDir)
+}
+
+// ConfFiles lists the fragment files of dir in the order they are merged. A missing dir has none.
+func ConfFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || !IsConfigFile(e.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readFragments decodes every fragment file of dir. Problems are prefixed with the file name.
+func readFragments(configPath, dir string) ([]fragment, []Problem, error) {
+	files, err := ConfFiles(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading %s: %w", dir, err)
+	}
+
+	var fragments []fragment
+	var problems []Problem
+	for _, file := range files {
+		name := file
+		if rel, err := filepath.Rel(filepath.Dir(configPath), file); err == nil {
+			name = rel
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading %s: %w", name, err)
+		}
+		data, err = toJSON(file, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		f := fragment{name: name}
+		decodeProblems, err := decodeStrict(data, &f)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for _, p := range decodeProblems {
+			p.Path = name + ":" + p.Path
+			problems = append(problems, p)
+		}
+		fragments = append(fragments, f)
+	}
+	return fragments, problems, nil
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files (path relative to dir -> content) and returns dir
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// problems returns the problems of a validation error as strings
+func problems(t *testing.T, err error) []string {
+	t.Helper()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got %v, want a validation error", err)
+	}
+	var out []string
+	for _, p := range verr.Problems {
+		out = append(out, p.String())
+	}
+	return out
+}
+
+func TestLoadFormats(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{"config.json", `{
+  "apiKey": "key",
+  "apiURL": "http://immich:2283/api",
+  "workers": 4,
+  "skipVideos": true,
+  "albums": [{"url": "https://photos.app.goo.gl/abc", "syncInterval": "6h", "notify": ["ops"]}],
+  "notifications": [{"name": "ops", "type": "ntfy", "url": "https://ntfy.sh/photos"}]
+}`},
+		{"config.yaml", `
+apiKey: key
+apiURL: http://immich:2283/api
+workers: 4
+skipVideos: true
+albums:
+  - url: https://photos.app.goo.gl/abc
+    syncInterval: 6h
+    notify: [ops]
+notifications:
+  - name: ops
+    type: ntfy
+    url: https://ntfy.sh/photos
+`},
+		{"config.YML", `{apiKey: key, apiURL: "http://immich:2283/api", workers: 4, skipVideos: true,
+  albums: [{url: "https://photos.app.goo.gl/abc", syncInterval: 6h, notify: [ops]}],
+  notifications: [{name: ops, type: ntfy, url: "https://ntfy.sh/photos"}]}`},
+		{"config.toml", `
+apiKey = "key"
+apiURL = "http://immich:2283/api"
+workers = 4
+skipVideos = true
+
+[[albums]]
+url = "https://photos.app.goo.gl/abc"
+syncInterval = "6h"
+notify = ["ops"]
+
+[[notifications]]
+name = "ops"
+type = "ntfy"
+url = "https://ntfy.sh/photos"
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			clearEnv(t)
+			dir := writeFiles(t, map[string]string{tt.file: tt.content})
+			cfg, err := Load(filepath.Join(dir, tt.file), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.ApiKey != "key" || cfg.ApiURL != "http://immich:2283/api" || cfg.Workers != 4 || !cfg.SkipVideos {
+				t.Errorf("got %+v", cfg)
+			}
+			albums := cfg.AllAlbums()
+			if len(albums) != 1 || albums[0].URL != "https://photos.app.goo.gl/abc" || albums[0].SyncInterval != "6h" || len(albums[0].Notify) != 1 {
+				t.Errorf("got albums %+v", albums)
+			}
+			if len(cfg.Notifications) != 1 || cfg.Notifications[0].Type != NotifyNtfy {
+				t.Errorf("got notifications %+v", cfg.Notifications)
+			}
+		})
+	}
+}
+
+func TestLoadFormatErrors(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+		err     string // substring of a decoding error
+		problem string // or a validation problem
+	}{
+		{"config.yaml", "apiKey: [unclosed", "invalid YAML", ""},
+		{"config.toml", "apiKey = ", "invalid TOML", ""},
+		{"config.json", `{"apiKey": "key",}`, "invalid JSON at line 1", ""},
+		{"config.yaml", "apiKey: key\napiURL: http://immich:2283/api\nworkers: many\nalbums: [{url: https://photos.app.goo.gl/abc}]", "", "workers: expected int, got string"},
+		{"config.toml", "apiKey = \"key\"\napiURL = \"http://immich:2283/api\"\nworkerz = 2\n[[albums]]\nurl = \"https://photos.app.goo.gl/abc\"", "", "workerz: unknown field"},
+		{"config.yaml", "", "", "apiURL: required (or set IMMICH_API_URL)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file+" "+tt.content, func(t *testing.T) {
+			clearEnv(t)
+			dir := writeFiles(t, map[string]string{tt.file: tt.content})
+			_, err := Load(filepath.Join(dir, tt.file), nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.err != "" {
+				if !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if got := problems(t, err); len(got) == 0 || got[0] != tt.problem {
+				t.Errorf("got problems %q, want %q first", got, tt.problem)
+			}
+		})
+	}
+}
+
+const mainConfig = `{"apiKey": "key", "apiURL": "http://immich:2283/api", "albums": [{"url": "https://photos.app.goo.gl/main"}]}`
+
+func TestConfDirMerge(t *testing.T) {
+	clearEnv(t)
+	dir := writeFiles(t, map[string]string{
+		"config.json":            mainConfig,
+		"conf.d/20-b.yaml":       "albums:\n  - url: https://photos.app.goo.gl/b\n",
+		"conf.d/10-a.toml":       "[[googlePhotos]]\nurl = \"https://photos.app.goo.gl/a\"\n",
+		"conf.d/30-c.json":       `{"albums": [{"url": "https://photos.app.goo.gl/c1"}, {"url": "https://photos.app.goo.gl/c2"}]}`,
+		"conf.d/.hidden.json":    `{"albums": [{"url": "https://photos.app.goo.gl/hidden"}]}`,
+		"conf.d/notes.txt":       "not a config",
+		"conf.d/sub/nested.json": `{"albums": [{"url": "https://photos.app.goo.gl/nested"}]}`,
+	})
+	cfg, err := Load(filepath.Join(dir, "config.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ac := range cfg.AllAlbums() {
+		got = append(got, strings.TrimPrefix(ac.URL, "https://photos.app.goo.gl/"))
+	}
+	// googlePhotos entries come before albums, each in config then fragment file order
+	if want := "a,main,b,c1,c2"; strings.Join(got, ",") != want {
+		t.Errorf("got albums %v, want %s", got, want)
+	}
+}
+
+func TestConfDirCustom(t *testing.T) {
+	clearEnv(t)
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":         "apiKey: key\napiURL: http://immich:2283/api\nconfDir: albums.d\n",
+		"albums.d/one.yaml":   "albums: [{url: https://photos.app.goo.gl/one}]\n",
+		"conf.d/ignored.yaml": "albums: [{url: https://photos.app.goo.gl/ignored}]\n",
+	})
+	cfg, err := Load(filepath.Join(dir, "config.yaml"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if albums := cfg.AllAlbums(); len(albums) != 1 || albums[0].URL != "https://photos.app.goo.gl/one" {
+		t.Errorf("got albums %+v", albums)
+	}
+
+	abs := filepath.Join(dir, "albums.d")
+	if got := ConfDir(filepath.Join(dir, "config.yaml"), abs); got != abs {
+		t.Errorf("absolute confDir resolved to %s", got)
+	}
+	if got := ConfDir("/etc/immich-sync/config.json", ""); got != "/etc/immich-sync/conf.d" {
+		t.Errorf("default confDir resolved to %s", got)
+	}
+}
+
+func TestConfDirProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment string
+		content  string
+		problems []string // empty if Load fails before validation
+		err      string
+	}{
+		{
+			name:     "invalid album",
+			fragment: "conf.d/10-a.yaml",
+			content:  "albums:\n  - url: https://example.com/x\n  - url: https://photos.app.goo.gl/ok\n    deletionPolicy: purge\n",
+			problems: []string{
+				`conf.d/10-a.yaml:albums[0].url: not a Google Photos share link (expected https://photos.app.goo.gl/... or https://photos.google.com/share/...), got "https://example.com/x"`,
+				`conf.d/10-a.yaml:albums[1].deletionPolicy: must be "keep", "remove" or "trash", got "purge"`,
+			},
+		},
+		{
+			name:     "duplicate of the main config",
+			fragment: "conf.d/dup.json",
+			content:  `{"albums": [{"url": "https://photos.app.goo.gl/main/"}]}`,
+			problems: []string{"conf.d/dup.json:albums[0].url: duplicate of albums[0].url"},
+		},
+		{
+			name:     "forbidden keys",
+			fragment: "conf.d/global.toml",
+			content:  "apiKey = \"other\"\nworkers = 8\n[[albums]]\nurl = \"https://photos.app.goo.gl/ok\"\nsyncIntervall = \"1h\"\n",
+			problems: []string{
+				"conf.d/global.toml:albums[0].syncIntervall: unknown field",
+				"conf.d/global.toml:apiKey: unknown field",
+				"conf.d/global.toml:workers: unknown field",
+			},
+		},
+		{
+			name:     "wrong type",
+			fragment: "conf.d/type.yaml",
+			content:  "albums: {url: https://photos.app.goo.gl/ok}\n",
+			problems: []string{"conf.d/type.yaml:albums: expected []config.AlbumConfig, got object"},
+		},
+		{
+			name:     "syntax error",
+			fragment: "conf.d/broken.yaml",
+			content:  "albums: [\n",
+			err:      "conf.d/broken.yaml: invalid YAML",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			dir := writeFiles(t, map[string]string{"config.json": mainConfig, tt.fragment: tt.content})
+			_, err := Load(filepath.Join(dir, "config.json"), nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.err != "" {
+				if !strings.HasPrefix(err.Error(), tt.err) {
+					t.Errorf("got %v, want prefix %q", err, tt.err)
+				}
+				return
+			}
+			got := problems(t, err)
+			if strings.Join(got, "\n") != strings.Join(tt.problems, "\n") {
+				t.Errorf("got problems\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.problems, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Accepted ranges of numeric settings
+const (
+	MaxWorkers       = 64
+	MaxAlbumWorkers  = 16
+	MinSyncInterval  = time.Minute
+	MaxRetryAttempts = 100
+	MinRetryBackoff  = 10 * time.Second
+)
+
+// Problem is a single invalid setting, identified by its JSON path (e.g. "googlePhotos[1].syncInterval")
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return p.Path + ": " + p.Message
+}
+
+// ValidationError lists every problem found in a config file
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("invalid config (%d problems):", len(e.Problems)))
+	for _, p := range e.Problems {
+		lines = append(lines, "  - "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validator collects problems and warnings while walking a config
+type validator struct {
+	problems []Problem
+	warnings []string
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(path, format string, args ...interface{}) {
+	v.warnings = append(v.warnings, Problem{Path: path, Message: fmt.Sprintf(format, args...)}.String())
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// decodeStrict unmarshals JSON data into target, a struct pointer. Unknown keys and type mismatches
+// are returned as problems so they can be reported together with the rest of the validation;
+// err is only set when data is not valid JSON at all.
+func decodeStrict(data []byte, target interface{}) (problems []Problem, err error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, col := position(data, syntaxErr.Offset)
+			return nil, fmt.Errorf("invalid JSON at line %d, column %d: %w", line, col, err)
+		}
+		return nil, err
+	}
+
+	v := &validator{}
+	unknownFields(v, raw, reflect.TypeOf(target), "")
+
+	if err := json.Unmarshal(data, target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			v.errorf(typeErr.Field, "expected %s, got %s", typeErr.Type, typeErr.Value)
+		} else {
+			v.errorf("", "%v", err)
+		}
+	}
+	return v.problems, nil
+}
+
+// unknownFields reports keys of raw that do not map to a field of t
+func unknownFields(v *validator, raw interface{}, t reflect.Type, path string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fieldPath := joinPath(path, key)
+			field, ok := fields[key]
+			if !ok {
+				// encoding/json matches keys case-insensitively; a wrong case is almost always a typo
+				for name := range fields {
+					if strings.EqualFold(name, key) {
+						v.errorf(fieldPath, "unknown field, did you mean %q?", name)
+						ok = true
+						break
+					}
+				}
+				if !ok {
+					v.errorf(fieldPath, "unknown field")
+				}
+				continue
+			}
+			unknownFields(v, obj[key], field.Type, fieldPath)
+		}
+	case reflect.Slice:
+		list, ok := raw.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range list {
+			unknownFields(v, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// jsonFields maps the JSON names of a struct to its fields
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// position converts a byte offset into a 1-based line and column
+func position(data []byte, offset int64) (line, col int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// Validate checks the config for invalid values. All problems are returned together
+// as a *ValidationError; non-fatal issues are returned as warnings.
+func (c *Config) Validate() (warnings []string, err error) {
+	return c.validate(nil, nil)
+}
+
+// validate runs Validate, reporting the given decode problems first. Albums of conf.d fragments
+// are validated with paths prefixed by their file name, before they are merged into c.
+func (c *Config) validate(problems []Problem, fragments []fragment) (warnings []string, err error) {
+	v := &validator{problems: problems}
+
+	if c.ApiURL == "" {
+		v.errorf("apiURL", "required (or set IMMICH_API_URL)")
+	} else if u, err := url.Parse(c.ApiURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.errorf("apiURL", "must be an http(s) URL like http://immich:2283/api, got %q", c.ApiURL)
+	}
+	if c.ApiKey == "" {
+		v.errorf("apiKey", "required (or set IMMICH_API_KEY)")
+	}
+	if c.Workers < 0 || c.Workers > MaxWorkers {
+		v.errorf("workers", "must be between 0 (default) and %d, got %d", MaxWorkers, c.Workers)
+	}
+	if c.AlbumWorkers < 0 || c.AlbumWorkers > MaxAlbumWorkers {
+		v.errorf("albumWorkers", "must be between 0 (default) and %d, got %d", MaxAlbumWorkers, c.AlbumWorkers)
+	}
+	if c.RetryAttempts < 0 || c.RetryAttempts > MaxRetryAttempts {
+		v.errorf("retryAttempts", "must be between 0 (default) and %d, got %d", MaxRetryAttempts, c.RetryAttempts)
+	}
+	if _, err := c.ParseRetryBackoff(); err != nil {
+		v.errorf("retryBackoff", "%v", err)
+	}
+	if c.KeepReports < 0 {
+		v.errorf("keepReports", "must not be negative, got %d", c.KeepReports)
+	}
+	switch c.WriteMetadata {
+	case "", MetadataEmbed, MetadataSidecar:
+	default:
+		v.errorf("writeMetadata", "must be %q or %q, got %q", MetadataEmbed, MetadataSidecar, c.WriteMetadata)
+	}
+
+	switch c.TrashPolicy {
+	case "", TrashRespect, TrashRestore, TrashReupload:
+	default:
+		v.errorf("trashPolicy", "must be %q, %q or %q, got %q", TrashRespect, TrashRestore, TrashReupload, c.TrashPolicy)
+	}
+
+	notifications := make(map[string]bool)
+	for i, n := range c.Notifications {
+		n.validate(v, fmt.Sprintf("notifications[%d]", i), notifications)
+	}
+
+	seen := make(map[string]string) // normalized URL -> path of first occurrence
+	for i, ac := range c.GooglePhotos {
+		ac.validate(v, fmt.Sprintf("googlePhotos[%d]", i), seen, notifications)
+	}
+	for i, ac := range c.Albums {
+		ac.validate(v, fmt.Sprintf("albums[%d]", i), seen, notifications)
+	}
+	for _, f := range fragments {
+		for i, ac := range f.GooglePhotos {
+			ac.validate(v, fmt.Sprintf("%s:googlePhotos[%d]", f.name, i), seen, notifications)
+		}
+		for i, ac := range f.Albums {
+			ac.validate(v, fmt.Sprintf("%s:albums[%d]", f.name, i), seen, notifications)
+		}
+	}
+
+	return v.warnings, v.err()
+}
+
+func (ac AlbumConfig) validate(v *validator, path string, seen map[string]string, notifications map[string]bool) {
+	if ac.URL == "" {
+		v.errorf(path+".url", "required")
+	} else {
+		if ac.Source == "" || ac.Source == DefaultSource {
+			if !isGooglePhotosShareURL(ac.URL) {
+				v.errorf(path+".url", "not a Google Photos share link (expected https://photos.app.goo.gl/... or https://photos.google.com/share/...), got %q", ac.URL)
+			}
+		}
+		key := strings.TrimRight(strings.TrimSpace(ac.URL), "/")
+		if first, ok := seen[key]; ok {
+			v.errorf(path+".url", "duplicate of %s.url", first)
+		} else {
+			seen[key] = path
+		}
+	}
+
+	if ac.ImmichAlbumID != "" && ac.AlbumName != "" {
+		v.warnf(path, "both immichAlbumId and albumName are set; the album is matched by immichAlbumId and albumName is only used in descriptions")
+	}
+
+	if _, err := ac.ParseSchedule(); err != nil {
+		field := "syncInterval"
+		if ac.Schedule != "" {
+			field = "schedule"
+		} else if ac.Timezone != "" {
+			field = "timezone"
+		}
+		v.errorf(path+"."+field, "%v", err)
+	} else if ac.Schedule == "" && ac.SyncInterval != "" {
+		if interval, _ := time.ParseDuration(ac.SyncInterval); interval < MinSyncInterval {
+			v.errorf(path+".syncInterval", "must be at least %s, got %q", MinSyncInterval, ac.SyncInterval)
+		}
+	}
+
+	switch ac.DeletionPolicy {
+	case "", DeletionKeep, DeletionRemove, DeletionTrash:
+	default:
+		v.errorf(path+".deletionPolicy", "must be %q, %q or %q, got %q", DeletionKeep, DeletionRemove, DeletionTrash, ac.DeletionPolicy)
+	}
+	if t := ac.DeletionThreshold; t != nil && !(*t >= 0 && *t <= 1) { // also rejects NaN
+		v.errorf(path+".deletionThreshold", "must be between 0 and 1, got %g", *t)
+	}
+
+	for i, name := range ac.Notify {
+		if !notifications[name] {
+			v.errorf(fmt.Sprintf("%s.notify[%d]", path, i), "no notification named %q", name)
+		}
+	}
+}
+
+// isGooglePhotosShareURL accepts shared album links, including short links that redirect to one
+func isGooglePhotosShareURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return false
+	}
+	path := strings.Trim(u.Path, "/")
+	switch strings.ToLower(u.Host) {
+	case "photos.app.goo.gl":
+		return path != ""
+	case "goo.gl":
+		return strings.HasPrefix(path, "photos/") && len(path) > len("photos/")
+	case "photos.google.com":
+		parts := strings.Split(path, "/")
+		// /share/<key> or /u/<n>/share/<key>
+		if len(parts) >= 4 && parts[0] == "u" {
+			parts = parts[2:]
+		}
+		return len(parts) >= 2 && parts[0] == "share" && parts[1] != ""
+	}
+	return false
+}
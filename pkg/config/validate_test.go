@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateWorkerCounts(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   int
+		problem string // empty if valid
+	}{
+		{"workers", 0, ""},
+		{"workers", MaxWorkers, ""},
+		{"workers", -1, "workers: must be between 0 (default) and 64, got -1"},
+		{"workers", MaxWorkers + 1, "workers: must be between 0 (default) and 64, got 65"},
+		{"albumWorkers", 0, ""},
+		{"albumWorkers", -1, "albumWorkers: must be between 0 (default) and 16, got -1"},
+		{"albumWorkers", MaxAlbumWorkers + 1, "albumWorkers: must be between 0 (default) and 16, got 17"},
+		{"retryAttempts", 0, ""},
+		{"retryAttempts", -1, fmt.Sprintf("retryAttempts: must be between 0 (default) and %d, got -1", MaxRetryAttempts)},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s=%d", tt.key, tt.value), func(t *testing.T) {
+			clearEnv(t)
+			path := filepath.Join(t.TempDir(), "config.json")
+			data := fmt.Sprintf(`{"apiKey": "key", "apiURL": "http://immich:2283/api", %q: %d, "albums": [{"url": "https://photos.app.goo.gl/abc"}]}`, tt.key, tt.value)
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Load(path, nil)
+			if tt.problem == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got %v, want a validation error", err)
+			}
+			if len(verr.Problems) != 1 || verr.Problems[0].String() != tt.problem {
+				t.Errorf("problems %v, want %q", verr.Problems, tt.problem)
+			}
+		})
+	}
+}
+
+func TestValidateDeletionThreshold(t *testing.T) {
+	tests := []struct {
+		value   string // JSON, empty to leave it unset
+		env     string // IMMICH_SYNC_ALBUMS_0_DELETION_THRESHOLD, if set
+		want    float64
+		problem string // empty if valid
+	}{
+		{"", "", DefaultDeletionThreshold, ""},
+		{"0", "", 0, ""},
+		{"0.5", "", 0.5, ""},
+		{"1", "", 1, ""},
+		{"", "0", 0, ""},
+		{"-0.1", "", 0, "albums[0].deletionThreshold: must be between 0 and 1, got -0.1"},
+		{"1.5", "", 0, "albums[0].deletionThreshold: must be between 0 and 1, got 1.5"},
+		{"", "NaN", 0, "albums[0].deletionThreshold: must be between 0 and 1, got NaN"},
+	}
+	for _, tt := range tests {
+		name := tt.value
+		if tt.env != "" {
+			name = "env " + tt.env
+		}
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			if tt.env != "" {
+				t.Setenv("IMMICH_SYNC_ALBUMS_0_DELETION_THRESHOLD", tt.env)
+			}
+			album := `{"url": "https://photos.app.goo.gl/abc"}`
+			if tt.value != "" {
+				album = fmt.Sprintf(`{"url": "https://photos.app.goo.gl/abc", "deletionThreshold": %s}`, tt.value)
+			}
+			path := filepath.Join(t.TempDir(), "config.json")
+			data := fmt.Sprintf(`{"apiKey": "key", "apiURL": "http://immich:2283/api", "albums": [%s]}`, album)
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path, nil)
+			if tt.problem == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := cfg.AllAlbums()[0].MaxDeletionFraction(); got != tt.want {
+					t.Errorf("threshold %g, want %g", got, tt.want)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got %v, want a validation error", err)
+			}
+			if len(verr.Problems) != 1 || verr.Problems[0].String() != tt.problem {
+				t.Errorf("problems %v, want %q", verr.Problems, tt.problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the next activation time after a given instant
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// Every returns a schedule firing at a fixed interval after each run
+func Every(d time.Duration) Schedule {
+	return interval(d)
+}
+
+type interval time.Duration
+
+func (i interval) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(i))
+}
+
+// Expression is a parsed standard 5-field cron expression evaluated in a time zone
+type Expression struct {
+	minute, hour, dom, month, dow uint64 // bit sets of allowed values
+	domStar, dowStar              bool
+	loc                           *time.Location
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// Parse parses "minute hour day-of-month month day-of-week" or a descriptor such as
+// "@daily". Fields accept *, lists, ranges, steps and month/day names. A nil loc means UTC.
+func Parse(expr string, loc *time.Location) (*Expression, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	spec := strings.TrimSpace(expr)
+	if d, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	} else if strings.HasPrefix(spec, "@") {
+		return nil, fmt.Errorf("unknown cron descriptor %q", expr)
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields (minute hour day-of-month month day-of-week), got %d", expr, len(fields))
+	}
+
+	e := &Expression{loc: loc}
+	var err error
+	if e.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute field: %w", err)
+	}
+	if e.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour field: %w", err)
+	}
+	if e.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day-of-month field: %w", err)
+	}
+	if e.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("month field: %w", err)
+	}
+	// Day-of-week accepts 7 as an alias for Sunday
+	if e.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("day-of-week field: %w", err)
+	}
+	if e.dow&(1<<7) != 0 {
+		e.dow = e.dow&^(1<<7) | 1
+	}
+	e.domStar = fields[2] == "*" || fields[2] == "?"
+	e.dowStar = fields[4] == "*" || fields[4] == "?"
+	return e, nil
+}
+
+// parseField parses a comma separated list of values, ranges and steps into a bit set
+func parseField(field string, min, max int, names map[string]int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = parseValue(a, min, max, names); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(b, min, max, names); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			v, err := parseValue(rangePart, min, max, names)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if !hasStep {
+				hi = v
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func parseValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, min, max)
+	}
+	return v, nil
+}
+
+// Next returns the first matching minute strictly after the given time, in the expression's zone.
+// Like cron, a time skipped by a DST change fires when the clock jumps past it, and a time
+// repeated by one fires once.
+func (e *Expression) Next(after time.Time) time.Time {
+	// Search wall-clock times, represented in UTC, so DST changes cannot skip or repeat any
+	local := after.In(e.loc)
+	t := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), 0, 0, time.UTC).Add(time.Minute)
+	// Five years covers every satisfiable expression (e.g. Feb 29 on a given weekday)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if e.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !e.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if e.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if e.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		if next, ok := e.resolve(t, after); ok {
+			return next
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+// resolve returns the first instant after the given time at which the zone's clock shows wall.
+// A wall time skipped by a DST change resolves to the end of the gap.
+func (e *Expression) resolve(wall, after time.Time) (time.Time, bool) {
+	// The offsets before and after a DST change on that day; equal on most days
+	_, before := wall.Add(-24 * time.Hour).In(e.loc).Zone()
+	_, later := wall.Add(24 * time.Hour).In(e.loc).Zone()
+
+	exists := false
+	for _, offset := range []int{before, later} {
+		t := wall.Add(-time.Duration(offset) * time.Second).In(e.loc)
+		if t.Hour() != wall.Hour() || t.Minute() != wall.Minute() || t.Day() != wall.Day() {
+			continue
+		}
+		exists = true
+		if t.After(after) {
+			return t, true
+		}
+	}
+	if exists {
+		return time.Time{}, false
+	}
+
+	// Skipped: the clock moved forward, so the old offset places wall just past the gap
+	gapEnd, _ := wall.Add(-time.Duration(before) * time.Second).In(e.loc).ZoneBounds()
+	return gapEnd, gapEnd.After(after)
+}
+
+// dayMatches applies cron's rule: if both day fields are restricted, either may match
+func (e *Expression) dayMatches(t time.Time) bool {
+	domMatch := e.dom&(1<<uint(t.Day())) != 0
+	dowMatch := e.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case e.domStar && e.dowStar:
+		return true
+	case e.domStar:
+		return dowMatch
+	case e.dowStar:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
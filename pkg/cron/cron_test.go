@@ -0,0 +1,266 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"1-2-3 * * * *",
+		"@fortnightly",
+	} {
+		if _, err := Parse(expr, nil); err == nil {
+			t.Errorf("Parse(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	tests := []struct {
+		expr  string
+		after string
+		want  []string // consecutive activations
+	}{
+		{"* * * * *", "2026-01-01 10:00", []string{"2026-01-01 10:01", "2026-01-01 10:02"}},
+		{"*/15 * * * *", "2026-01-01 10:07", []string{"2026-01-01 10:15", "2026-01-01 10:30", "2026-01-01 10:45", "2026-01-01 11:00"}},
+		{"5,35 * * * *", "2026-01-01 10:05", []string{"2026-01-01 10:35", "2026-01-01 11:05"}},
+		{"0 9-17/4 * * *", "2026-01-01 08:00", []string{"2026-01-01 09:00", "2026-01-01 13:00", "2026-01-01 17:00", "2026-01-02 09:00"}},
+		{"10-12 0 * * *", "2026-01-01 00:10", []string{"2026-01-01 00:11", "2026-01-01 00:12", "2026-01-02 00:10"}},
+		{"0 3/6 * * *", "2026-01-01 00:00", []string{"2026-01-01 03:00", "2026-01-01 09:00", "2026-01-01 15:00", "2026-01-01 21:00", "2026-01-02 03:00"}},
+		{"0 0 1,15 * *", "2026-01-01 00:00", []string{"2026-01-15 00:00", "2026-02-01 00:00"}},
+		{"0 0 31 * *", "2026-01-31 00:00", []string{"2026-03-31 00:00", "2026-05-31 00:00"}},
+		{"0 12 * jan,jul *", "2026-02-01 00:00", []string{"2026-07-01 12:00", "2026-07-02 12:00"}},
+		{"30 8 * * mon-fri", "2026-01-02 09:00", []string{"2026-01-05 08:30", "2026-01-06 08:30"}}, // Friday to Monday
+		{"0 0 * * 7", "2026-01-01 00:00", []string{"2026-01-04 00:00", "2026-01-11 00:00"}},        // 7 is Sunday
+		{"0 0 * * SUN", "2026-01-01 00:00", []string{"2026-01-04 00:00"}},
+		{"0 0 ? * 1", "2026-01-01 00:00", []string{"2026-01-05 00:00"}},
+		// Both day fields restricted: either matches (the 13th or a Friday)
+		{"0 0 13 * fri", "2026-01-01 00:00", []string{"2026-01-02 00:00", "2026-01-09 00:00", "2026-01-13 00:00", "2026-01-16 00:00"}},
+		// Only one restricted: that one alone decides
+		{"0 0 13 * *", "2026-01-01 00:00", []string{"2026-01-13 00:00", "2026-02-13 00:00"}},
+		{"0 0 29 2 *", "2026-01-01 00:00", []string{"2028-02-29 00:00", "2032-02-29 00:00"}},
+		{"@hourly", "2026-01-01 10:30", []string{"2026-01-01 11:00"}},
+		{"@daily", "2026-01-01 10:30", []string{"2026-01-02 00:00"}},
+		{"@midnight", "2026-01-01 00:00", []string{"2026-01-02 00:00"}},
+		{"@weekly", "2026-01-01 10:30", []string{"2026-01-04 00:00"}},
+		{"@monthly", "2026-01-15 10:30", []string{"2026-02-01 00:00"}},
+		{"@yearly", "2026-01-15 10:30", []string{"2027-01-01 00:00"}},
+		{"@annually", "2026-01-15 10:30", []string{"2027-01-01 00:00"}},
+		{"  @DAILY ", "2026-01-01 10:30", []string{"2026-01-02 00:00"}},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.expr, nil)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		after := utc(tt.after)
+		for _, w := range tt.want {
+			got := e.Next(after)
+			if want := utc(w); !got.Equal(want) {
+				t.Errorf("%q after %s = %s, want %s", tt.expr, after.Format(time.DateTime), got.Format(time.DateTime), want.Format(time.DateTime))
+				break
+			}
+			after = got
+		}
+	}
+}
+
+func TestNextSubMinute(t *testing.T) {
+	e, _ := Parse("*/5 * * * *", nil)
+	after := time.Date(2026, 1, 1, 10, 4, 59, 999, time.UTC)
+	if got, want := e.Next(after), time.Date(2026, 1, 1, 10, 5, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	// Strictly after: a run finishing within its own minute does not fire again
+	after = time.Date(2026, 1, 1, 10, 5, 30, 0, time.UTC)
+	if got, want := e.Next(after), time.Date(2026, 1, 1, 10, 10, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestNextUnsatisfiable(t *testing.T) {
+	e, err := Parse("0 0 31 2 *", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Next(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("got %s, want zero time", got)
+	}
+}
+
+func TestNextTimeZone(t *testing.T) {
+	tokyo := loadLocation(t, "Asia/Tokyo")
+	e, _ := Parse("0 9 * * *", tokyo)
+	got := e.Next(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) // 09:00 in Tokyo
+	if want := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got.UTC(), want)
+	}
+	if got.Location() != tokyo {
+		t.Errorf("result in %s, want Asia/Tokyo", got.Location())
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	brussels := loadLocation(t, "Europe/Brussels")
+	newYork := loadLocation(t, "America/New_York")
+	at := func(loc *time.Location, y int, m time.Month, d, h, min int, zone string) time.Time {
+		t := time.Date(y, m, d, h, min, 0, 0, loc)
+		// Pin ambiguous wall times to the named zone
+		if name, _ := t.Zone(); name != zone {
+			for _, shift := range []time.Duration{-time.Hour, time.Hour} {
+				if alt := t.Add(shift); alt.Hour() == h && alt.Minute() == min {
+					if name, _ := alt.Zone(); name == zone {
+						return alt
+					}
+				}
+			}
+			panic("no " + zone + " occurrence of the wall time")
+		}
+		return t
+	}
+
+	tests := []struct {
+		name  string
+		expr  string
+		loc   *time.Location
+		after time.Time
+		want  []time.Time
+	}{
+		{
+			// 02:00-03:00 does not exist on 2026-03-29: the 02:30 run happens at 03:00
+			name:  "spring forward, daily in the gap",
+			expr:  "30 2 * * *",
+			loc:   brussels,
+			after: at(brussels, 2026, 3, 28, 3, 0, "CET"),
+			want: []time.Time{
+				at(brussels, 2026, 3, 29, 3, 0, "CEST"),
+				at(brussels, 2026, 3, 30, 2, 30, "CEST"),
+			},
+		},
+		{
+			name:  "spring forward, every 20 minutes",
+			expr:  "*/20 * * * *",
+			loc:   brussels,
+			after: at(brussels, 2026, 3, 29, 1, 40, "CET"),
+			want: []time.Time{
+				at(brussels, 2026, 3, 29, 3, 0, "CEST"),
+				at(brussels, 2026, 3, 29, 3, 20, "CEST"),
+			},
+		},
+		{
+			name:  "spring forward, outside the gap",
+			expr:  "0 4 * * *",
+			loc:   brussels,
+			after: at(brussels, 2026, 3, 28, 12, 0, "CET"),
+			want:  []time.Time{at(brussels, 2026, 3, 29, 4, 0, "CEST")},
+		},
+		{
+			name:  "spring forward, New York",
+			expr:  "30 2 * * *",
+			loc:   newYork,
+			after: at(newYork, 2026, 3, 7, 12, 0, "EST"),
+			want: []time.Time{
+				at(newYork, 2026, 3, 8, 3, 0, "EDT"),
+				at(newYork, 2026, 3, 9, 2, 30, "EDT"),
+			},
+		},
+		{
+			// 02:00-03:00 happens twice on 2026-10-25: the 02:30 run happens once
+			name:  "fall back, daily in the repeated hour",
+			expr:  "30 2 * * *",
+			loc:   brussels,
+			after: at(brussels, 2026, 10, 25, 0, 0, "CEST"),
+			want: []time.Time{
+				at(brussels, 2026, 10, 25, 2, 30, "CEST"),
+				at(brussels, 2026, 10, 26, 2, 30, "CET"),
+			},
+		},
+		{
+			name:  "fall back, hourly",
+			expr:  "0 * * * *",
+			loc:   brussels,
+			after: at(brussels, 2026, 10, 25, 1, 30, "CEST"),
+			want: []time.Time{
+				at(brussels, 2026, 10, 25, 2, 0, "CEST"),
+				at(brussels, 2026, 10, 25, 3, 0, "CET"),
+				at(brussels, 2026, 10, 25, 4, 0, "CET"),
+			},
+		},
+		{
+			// A start during the second pass continues from there
+			name:  "fall back, starting in the second pass",
+			expr:  "*/5 * * * *",
+			loc:   brussels,
+			after: at(brussels, 2026, 10, 25, 2, 40, "CET"),
+			want: []time.Time{
+				at(brussels, 2026, 10, 25, 2, 45, "CET"),
+				at(brussels, 2026, 10, 25, 2, 50, "CET"),
+			},
+		},
+		{
+			name:  "fall back, New York",
+			expr:  "30 1 * * *",
+			loc:   newYork,
+			after: at(newYork, 2026, 10, 31, 12, 0, "EDT"),
+			want: []time.Time{
+				at(newYork, 2026, 11, 1, 1, 30, "EDT"),
+				at(newYork, 2026, 11, 2, 1, 30, "EST"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.expr, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after := tt.after
+			for _, want := range tt.want {
+				got := e.Next(after)
+				if !got.Equal(want) {
+					t.Fatalf("after %s: got %s, want %s", after, got, want)
+				}
+				after = got
+			}
+		})
+	}
+}
+
+func TestEvery(t *testing.T) {
+	start := time.Date(2026, 1, 1, 10, 7, 13, 0, time.UTC)
+	if got, want := Every(90*time.Minute).Next(start), start.Add(90*time.Minute); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"time"
 )
 
+// DefaultBaseURL is the Google Photos origin used for API calls such as pagination
+const DefaultBaseURL = "https://photos.google.com"
+
 const userAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
 
 const (
@@ -21,6 +24,16 @@ const (
 type Client struct {
 	client *http.Client
 	logger *slog.Logger
+
+	// BaseURL is the Google Photos origin for batchexecute calls (default DefaultBaseURL).
+	// Tests point it at a googlephotostest server.
+	BaseURL string
+
+	// SpoolDir is where downloads without a trustworthy Content-Length are buffered (default os.TempDir())
+	SpoolDir string
+
+	// OnRetry, if set, is called with the status code of every rate-limited (429) or 5xx response that is retried
+	OnRetry func(status int)
 }
 
 func NewClient(logger *slog.Logger) *Client {
@@ -33,7 +46,8 @@ func NewClient(logger *slog.Logger) *Client {
 			},
 			Timeout: 120 * time.Second,
 		},
-		logger: logger,
+		logger:  logger,
+		BaseURL: DefaultBaseURL,
 	}
 }
 
@@ -104,6 +118,9 @@ func (c *Client) doWithRetry(makeReq func() (*http.Request, error)) (*http.Respo
 			}
 		}
 		c.logger.Warn("Retryable HTTP error, retrying", "status", resp.StatusCode, "sleep", sleepTime, "attempt", i+1)
+		if c.OnRetry != nil {
+			c.OnRetry(resp.StatusCode)
+		}
 		time.Sleep(sleepTime)
 	}
 
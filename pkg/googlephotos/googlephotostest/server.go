@@ -0,0 +1,335 @@
+// Package googlephotostest provides an in-memory fake of the Google Photos shared album
+// endpoints used by the scraper, for offline end-to-end tests.
+package googlephotostest
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/googlephotos"
+)
+
+// DefaultPageSize is the number of items served per page when an album sets none
+const DefaultPageSize = 300
+
+// Item is a fixture media item of a fake album
+type Item struct {
+	ID          string
+	Width       int
+	Height      int
+	TakenAt     time.Time // zero omits the timestamp, like items without metadata
+	Description string
+	ContentType string // e.g. "image/jpeg" or "video/mp4"
+	Data        []byte // served for =d (and =dv for videos)
+	MotionVideo []byte // optional, served for =dv of images (motion photos)
+}
+
+// Album is a fixture shared album
+type Album struct {
+	Key      string // media key, used in /share/<key>
+	AuthKey  string // optional "key" query parameter of the share link
+	Title    string
+	Items    []Item
+	PageSize int // items per page; the first page is embedded in the HTML
+}
+
+// Server is a fake Google Photos origin serving shared album pages, batchexecute
+// pagination and =d/=dv media downloads from in-memory fixtures.
+type Server struct {
+	*httptest.Server
+
+	// Chunked serves media without Content-Length, forcing downloads to be spooled
+	Chunked bool
+
+	mu         sync.Mutex
+	albums     map[string]*Album
+	items      map[string]*Item
+	failStatus int
+	failCount  int
+	requests   map[string]int
+}
+
+// NewServer starts a fake Google Photos server. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		albums:   make(map[string]*Album),
+		items:    make(map[string]*Item),
+		requests: make(map[string]int),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/share/", s.handleAlbum)
+	mux.HandleFunc("/s/", s.handleShortLink)
+	mux.HandleFunc("/_/PhotosUi/data/batchexecute", s.handleBatchExecute)
+	mux.HandleFunc("/media/", s.handleMedia)
+	s.Server = httptest.NewServer(s.intercept(mux))
+	return s
+}
+
+// AddAlbum registers an album and returns its share URL
+func (s *Server) AddAlbum(a Album) string {
+	if a.PageSize <= 0 {
+		a.PageSize = DefaultPageSize
+	}
+	s.mu.Lock()
+	s.albums[a.Key] = &a
+	for i := range a.Items {
+		s.items[a.Items[i].ID] = &a.Items[i]
+	}
+	s.mu.Unlock()
+	return s.ShareURL(a.Key)
+}
+
+// ShareURL returns the canonical share URL of an album
+func (s *Server) ShareURL(key string) string {
+	s.mu.Lock()
+	a := s.albums[key]
+	s.mu.Unlock()
+	u := fmt.Sprintf("%s/share/%s", s.URL, key)
+	if a != nil && a.AuthKey != "" {
+		u += "?key=" + a.AuthKey
+	}
+	return u
+}
+
+// ShortURL returns a short link redirecting to the album, like photos.app.goo.gl links
+func (s *Server) ShortURL(key string) string {
+	return fmt.Sprintf("%s/s/%s", s.URL, key)
+}
+
+// Client returns a googlephotos.Client whose BaseURL points at this server
+func (s *Server) Client(logger *slog.Logger) *googlephotos.Client {
+	c := googlephotos.NewClient(logger)
+	c.BaseURL = s.URL
+	return c
+}
+
+// FailNext answers the next n requests with status. 429 responses carry "Retry-After: 0".
+func (s *Server) FailNext(n int, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failCount = n
+	s.failStatus = status
+}
+
+// Requests returns how many requests were received for a route:
+// "album", "batchexecute", "media" or "short".
+func (s *Server) Requests(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[route]
+}
+
+// intercept counts requests and applies injected failures
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[routeOf(r.URL.Path)]++
+		fail := 0
+		if s.failCount > 0 {
+			s.failCount--
+			fail = s.failStatus
+		}
+		s.mu.Unlock()
+
+		if fail != 0 {
+			if fail == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "0")
+			}
+			http.Error(w, http.StatusText(fail), fail)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func routeOf(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/share/"):
+		return "album"
+	case strings.HasPrefix(path, "/s/"):
+		return "short"
+	case strings.HasSuffix(path, "/batchexecute"):
+		return "batchexecute"
+	case strings.HasPrefix(path, "/media/"):
+		return "media"
+	}
+	return "other"
+}
+
+func (s *Server) album(key string) *Album {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.albums[key]
+}
+
+func (s *Server) handleShortLink(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/s/")
+	if s.album(key) == nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.Redirect(w, r, s.ShareURL(key), http.StatusFound)
+}
+
+// handleAlbum serves the shared album page with WIZ tokens and the ds:1 data blob
+func (s *Server) handleAlbum(w http.ResponseWriter, r *http.Request) {
+	key := strings.Trim(strings.TrimPrefix(r.URL.Path, "/share/"), "/")
+	a := s.album(key)
+	if a == nil || (a.AuthKey != "" && r.URL.Query().Get("key") != a.AuthKey) {
+		http.NotFound(w, r)
+		return
+	}
+
+	items, next := s.page(a, 0)
+	meta := make([]interface{}, 20)
+	meta[0] = a.Key
+	meta[1] = a.Title
+	meta[19] = a.AuthKey
+	data := []interface{}{nil, items, next, meta}
+	blob, _ := json.Marshal(data)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!doctype html><html><head>
+<meta property="og:title" content="%s · Jan 1–2 📸">
+<script>window.WIZ_global_data = {"FdrFJe":"-123456789","cfb2h":"boq_photosuiserver_20240101.00_p0","eptZe":"/_/PhotosUi/"};</script>
+</head><body>
+<script class="ds:1">AF_initDataCallback({key: 'ds:1', hash: '2', data:%s, sideChannel: {}});</script>
+</body></html>`, html.EscapeString(a.Title), blob)
+}
+
+// page returns the scraped item arrays starting at offset and the next page token ("" if last)
+func (s *Server) page(a *Album, offset int) ([]interface{}, interface{}) {
+	end := offset + a.PageSize
+	if end > len(a.Items) {
+		end = len(a.Items)
+	}
+	items := make([]interface{}, 0, end-offset)
+	for _, it := range a.Items[offset:end] {
+		items = append(items, s.itemArray(it))
+	}
+	if end >= len(a.Items) {
+		return items, nil
+	}
+	return items, "page:" + strconv.Itoa(end)
+}
+
+// itemArray encodes an item the way Google embeds it: [id, [url, w, h], takenMs, description]
+func (s *Server) itemArray(it Item) []interface{} {
+	var ts interface{}
+	if !it.TakenAt.IsZero() {
+		ts = it.TakenAt.UnixMilli()
+	}
+	var desc interface{}
+	if it.Description != "" {
+		desc = it.Description
+	}
+	return []interface{}{
+		it.ID,
+		[]interface{}{fmt.Sprintf("%s/media/%s", s.URL, it.ID), it.Width, it.Height},
+		ts,
+		desc,
+	}
+}
+
+// handleBatchExecute serves snAcKc pagination calls in Google's multi-line RPC format
+func (s *Server) handleBatchExecute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Query().Get("rpcids") != "snAcKc" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var outer [][][]interface{}
+	if err := json.Unmarshal([]byte(r.PostForm.Get("f.req")), &outer); err != nil || len(outer) == 0 || len(outer[0]) == 0 || len(outer[0][0]) < 2 {
+		http.Error(w, "bad f.req", http.StatusBadRequest)
+		return
+	}
+	innerJSON, _ := outer[0][0][1].(string)
+	var inner []interface{}
+	if err := json.Unmarshal([]byte(innerJSON), &inner); err != nil || len(inner) < 2 {
+		http.Error(w, "bad inner request", http.StatusBadRequest)
+		return
+	}
+	key, _ := inner[0].(string)
+	token, _ := inner[1].(string)
+	authKey := ""
+	if len(inner) > 3 {
+		authKey, _ = inner[3].(string)
+	}
+
+	a := s.album(key)
+	if a == nil || authKey != a.AuthKey || !strings.HasPrefix(token, "page:") {
+		http.Error(w, "unknown album or token", http.StatusBadRequest)
+		return
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(token, "page:"))
+	if err != nil || offset < 0 || offset > len(a.Items) {
+		http.Error(w, "bad token", http.StatusBadRequest)
+		return
+	}
+
+	items, next := s.page(a, offset)
+	payload, _ := json.Marshal([]interface{}{nil, items, next})
+	envelope, _ := json.Marshal([]interface{}{
+		[]interface{}{"wrb.fr", "snAcKc", string(payload), nil, nil, nil, "generic"},
+		[]interface{}{"di", 42},
+	})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, ")]}'\n\n%d\n%s\n", len(envelope), envelope)
+}
+
+// handleMedia serves <id>=d (original) and <id>=dv (video) downloads, including HEAD probes
+func (s *Server) handleMedia(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/media/")
+	id, variant, _ := strings.Cut(name, "=")
+
+	s.mu.Lock()
+	it := s.items[id]
+	s.mu.Unlock()
+	if it == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	isVideo := strings.HasPrefix(it.ContentType, "video/")
+	var body []byte
+	contentType := it.ContentType
+	switch {
+	case variant == "d":
+		body = it.Data
+	case variant == "dv" && isVideo:
+		body = it.Data
+	case variant == "dv" && it.MotionVideo != nil:
+		body, contentType = it.MotionVideo, "video/mp4"
+	case variant == "dv":
+		// Google answers =dv of plain stills with the image itself
+		body = it.Data
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if s.Chunked {
+		// Flushing before writing forces chunked transfer encoding without Content-Length
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+	} else {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	w.Write(body)
+}
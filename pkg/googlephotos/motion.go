@@ -0,0 +1,132 @@
+package googlephotos
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"warreth.dev/immich-sync/pkg/source"
+)
+
+// xmpScanLimit bounds how much of the image header is searched for motion photo XMP
+const xmpScanLimit = 256 * 1024
+
+var (
+	microVideoOffsetRe = regexp.MustCompile(`MicroVideoOffset(?:="|>)(\d+)`)
+	containerItemRe    = regexp.MustCompile(`<Container:Item\b[^>]*>`)
+	itemLengthRe       = regexp.MustCompile(`Item:Length="(\d+)"`)
+	motionPhotoRe      = regexp.MustCompile(`(?:MotionPhoto|MicroVideo)(?:="|>)1`)
+)
+
+// motionVideoLength returns the length of the MP4 embedded at the end of a motion photo,
+// read from the MicroVideo (legacy) or MotionPhoto (Container directory) XMP. Returns 0 if none.
+func motionVideoLength(header []byte) int64 {
+	xmp := string(header)
+	if m := microVideoOffsetRe.FindStringSubmatch(xmp); len(m) > 1 {
+		if n, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+			return n
+		}
+	}
+	for _, item := range containerItemRe.FindAllString(xmp, -1) {
+		if !strings.Contains(item, `Item:Semantic="MotionPhoto"`) {
+			continue
+		}
+		if m := itemLengthRe.FindStringSubmatch(item); len(m) > 1 {
+			if n, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// isMotionPhoto reports whether the XMP flags the image as a motion photo (MotionPhoto or the
+// legacy MicroVideo), whether or not it announces the length of an embedded video
+func isMotionPhoto(header []byte) bool {
+	return motionPhotoRe.Match(header)
+}
+
+// splitMotionPhoto detects a motion photo and separates it into still image and video.
+// The embedded MP4 trailer is preferred; otherwise Google's separate =dv stream is used.
+// On success media is truncated to the still image and media.LiveVideo is set.
+func splitMotionPhoto(client *Client, baseUrl string, media *source.Media, sf *spoolFile) error {
+	header := make([]byte, xmpScanLimit)
+	n, err := sf.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if videoLen := motionVideoLength(header[:n]); videoLen > 0 && videoLen < media.Size {
+		stillLen := media.Size - videoLen
+		video, size, checksum, err := spoolToDisk(client.SpoolDir, io.NewSectionReader(sf, stillLen, videoLen))
+		if err != nil {
+			return fmt.Errorf("failed to extract motion video: %w", err)
+		}
+		// Hash the still before truncating, so a failure leaves media describing the whole file
+		stillChecksum, err := checksumReader(io.NewSectionReader(sf, 0, stillLen))
+		if err != nil {
+			video.Close()
+			return err
+		}
+		if err := sf.Truncate(stillLen); err != nil {
+			video.Close()
+			return err
+		}
+		media.Size = stillLen
+		media.Checksum = stillChecksum
+		if _, err := sf.Seek(0, io.SeekStart); err != nil {
+			video.Close()
+			return err
+		}
+		media.LiveVideo = &source.Media{Body: video, Size: size, Ext: ".mp4", IsVideo: true, Checksum: checksum}
+		return nil
+	}
+
+	// No embedded video: Google serves the motion part of some motion photos as a separate stream.
+	// Only flagged photos are probed, to spare plain stills a request.
+	if !isMotionPhoto(header[:n]) {
+		return nil
+	}
+	probe, err := client.Head(baseUrl + "=dv")
+	if err != nil {
+		return err
+	}
+	probe.Body.Close()
+	if probe.StatusCode != 200 || !strings.HasPrefix(strings.ToLower(probe.Header.Get("Content-Type")), "video/") {
+		return nil
+	}
+
+	resp, err := client.Get(baseUrl + "=dv")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return fmt.Errorf("failed to download motion video: %d", resp.StatusCode)
+	}
+	body, size, checksum, err := client.mediaBody(resp, true)
+	if err != nil {
+		return fmt.Errorf("failed to read motion video: %w", err)
+	}
+	media.LiveVideo = &source.Media{
+		Body:     body,
+		Size:     size,
+		Ext:      extensionFromContentType(resp.Header.Get("Content-Type")),
+		IsVideo:  true,
+		Checksum: checksum,
+	}
+	return nil
+}
+
+// checksumReader returns the hex SHA-1 of everything r yields
+func checksumReader(r io.Reader) (string, error) {
+	hasher := sha1.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
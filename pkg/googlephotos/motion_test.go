@@ -0,0 +1,23 @@
+package googlephotos
+
+import "testing"
+
+func TestIsMotionPhoto(t *testing.T) {
+	tests := []struct {
+		xmp  string
+		want bool
+	}{
+		{`<rdf:Description Camera:MotionPhoto="1"/>`, true},
+		{`<rdf:Description GCamera:MicroVideo="1"/>`, true},
+		{`<GCamera:MotionPhoto>1</GCamera:MotionPhoto>`, true},
+		{`<rdf:Description Camera:MotionPhoto="0"/>`, false},
+		{`<rdf:Description Camera:MotionPhotoVersion="1"/>`, false},
+		{`<Container:Item Item:Semantic="MotionPhoto"/>`, false},
+		{`plain jpeg`, false},
+	}
+	for _, tt := range tests {
+		if got := isMotionPhoto([]byte(tt.xmp)); got != tt.want {
+			t.Errorf("isMotionPhoto(%s) = %v, want %v", tt.xmp, got, tt.want)
+		}
+	}
+}
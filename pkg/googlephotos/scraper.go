@@ -1,7 +1,6 @@
 package googlephotos
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"html"
@@ -11,6 +10,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"warreth.dev/immich-sync/pkg/source"
 )
 
 type Album struct {
@@ -403,7 +404,8 @@ func fetchNextPage(client *Client, mediaKey, authKey, pageToken, sourcePath stri
 	}
 
 	batchURL := fmt.Sprintf(
-		"https://photos.google.com%sdata/batchexecute?rpcids=snAcKc&source-path=%s&f.sid=%s&bl=%s&pageId=none&rt=c",
+		"%s%sdata/batchexecute?rpcids=snAcKc&source-path=%s&f.sid=%s&bl=%s&pageId=none&rt=c",
+		strings.TrimSuffix(client.BaseURL, "/"),
 		wiz.Path,
 		url.QueryEscape(sourcePath),
 		url.QueryEscape(wiz.SID),
@@ -577,58 +579,64 @@ func extensionFromContentType(contentType string) string {
 
 // DownloadMedia downloads original media from Google Photos.
 // Uses =d for original quality images (preserves motion photo data for Immich), =dv for videos.
-// Response is buffered to guarantee accurate Content-Length for the upload.
+// Bodies are streamed when Content-Length is known and spooled to disk otherwise (see mediaBody).
 // Returns: body, size, extension (e.g. ".jpg"), isVideo, error
 func DownloadMedia(client *Client, baseUrl string) (io.ReadCloser, int64, string, bool, error) {
+	media, err := downloadMedia(client, baseUrl, source.DownloadOptions{})
+	if err != nil {
+		return nil, 0, "", false, err
+	}
+	return media.Body, media.Size, media.Ext, media.IsVideo, nil
+}
+
+// downloadMedia implements DownloadMedia; with opts.Spool the body is always spooled and checksummed.
+// With opts.SplitMotion motion photos are separated into still image and media.LiveVideo.
+func downloadMedia(client *Client, baseUrl string, opts source.DownloadOptions) (*source.Media, error) {
 	// HEAD probe to detect content type without downloading body
 	probeResp, err := client.Head(baseUrl + "=d")
 	if err != nil {
-		return nil, 0, "", false, err
+		return nil, err
 	}
 	probeResp.Body.Close()
 
 	probeCt := probeResp.Header.Get("Content-Type")
 	isVideo := strings.HasPrefix(strings.ToLower(probeCt), "video/")
 
-	// Pure video: download with =dv
+	// Pure video: download with =dv, image: original with =d (motion photos are preserved as-is for Immich)
+	suffix, kind := "=d", "image"
 	if isVideo {
-		resp, err := client.Get(baseUrl + "=dv")
-		if err != nil {
-			return nil, 0, "", false, err
-		}
-		if resp.StatusCode != 200 {
-			resp.Body.Close()
-			return nil, 0, "", false, fmt.Errorf("failed to download video: %d", resp.StatusCode)
-		}
-		// Buffer video for accurate size
-		data, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, 0, "", false, fmt.Errorf("failed to read video data: %w", err)
-		}
-		ct := resp.Header.Get("Content-Type")
-		ext := extensionFromContentType(ct)
-		return io.NopCloser(bytes.NewReader(data)), int64(len(data)), ext, true, nil
+		suffix, kind = "=dv", "video"
 	}
 
-	// Image: download original with =d (motion photos are preserved as-is for Immich)
-	resp, err := client.Get(baseUrl + "=d")
+	resp, err := client.Get(baseUrl + suffix)
 	if err != nil {
-		return nil, 0, "", false, err
+		return nil, err
 	}
 	if resp.StatusCode != 200 {
 		resp.Body.Close()
-		return nil, 0, "", false, fmt.Errorf("failed to download image: %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to download %s: %d", kind, resp.StatusCode)
 	}
 
-	// Buffer to guarantee accurate size (HTTP Content-Length can be -1 for chunked responses)
-	data, err := io.ReadAll(resp.Body)
-	resp.Body.Close()
+	splitMotion := opts.SplitMotion && !isVideo
+	body, size, checksum, err := client.mediaBody(resp, opts.Spool || splitMotion)
 	if err != nil {
-		return nil, 0, "", false, fmt.Errorf("failed to read image data: %w", err)
+		return nil, fmt.Errorf("failed to read %s data: %w", kind, err)
 	}
 
 	ct := resp.Header.Get("Content-Type")
-	ext := extensionFromContentType(ct)
-	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), ext, false, nil
+	media := &source.Media{
+		Body:     body,
+		Size:     size,
+		Ext:      extensionFromContentType(ct),
+		IsVideo:  isVideo,
+		Checksum: checksum,
+	}
+
+	if splitMotion {
+		if err := splitMotionPhoto(client, baseUrl, media, body.(*spoolFile)); err != nil {
+			// Fall back to importing the file as a plain still image
+			client.logger.Warn("Failed to split motion photo, importing as still", "url", baseUrl, "error", err)
+		}
+	}
+	return media, nil
 }
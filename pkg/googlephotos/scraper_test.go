@@ -0,0 +1,265 @@
+package googlephotos_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"warreth.dev/immich-sync/pkg/googlephotos"
+	"warreth.dev/immich-sync/pkg/googlephotos/googlephotostest"
+	"warreth.dev/immich-sync/pkg/source"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func items(n int) []googlephotostest.Item {
+	base := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	out := make([]googlephotostest.Item, n)
+	for i := range out {
+		out[i] = googlephotostest.Item{
+			ID:          fmt.Sprintf("item%03d", i),
+			Width:       4000,
+			Height:      3000,
+			TakenAt:     base.Add(time.Duration(i) * time.Minute),
+			ContentType: "image/jpeg",
+			Data:        []byte(fmt.Sprintf("jpeg-%03d", i)),
+		}
+	}
+	return out
+}
+
+func sha1Hex(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestScrapeAlbumPagination(t *testing.T) {
+	srv := googlephotostest.NewServer()
+	defer srv.Close()
+	fixture := items(7)
+	fixture[3].Description = "Sunset \"at\" the beach"
+	fixture[5].TakenAt = time.Time{}
+	url := srv.AddAlbum(googlephotostest.Album{Key: "AF1QipAlbum", AuthKey: "secret", Title: "Summer & Friends", Items: fixture, PageSize: 3})
+
+	album, err := googlephotos.ScrapeAlbum(srv.Client(logger), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if album.Title != "Summer & Friends" {
+		t.Errorf("title = %q", album.Title)
+	}
+	if len(album.Photos) != len(fixture) {
+		t.Fatalf("got %d photos, want %d", len(album.Photos), len(fixture))
+	}
+	// The first page is embedded in the HTML, the other two come from batchexecute
+	if got := srv.Requests("batchexecute"); got != 2 {
+		t.Errorf("batchexecute requests = %d, want 2", got)
+	}
+	for i, p := range album.Photos {
+		want := fixture[i]
+		if p.ID != want.ID || p.Width != want.Width || p.Height != want.Height || p.Description != want.Description {
+			t.Errorf("photo %d = %+v, want %+v", i, p, want)
+		}
+		if !want.TakenAt.IsZero() && !p.TakenAt.Equal(want.TakenAt) {
+			t.Errorf("photo %d taken at %s, want %s", i, p.TakenAt, want.TakenAt)
+		}
+	}
+}
+
+func TestScrapeAlbumShortLink(t *testing.T) {
+	srv := googlephotostest.NewServer()
+	defer srv.Close()
+	srv.AddAlbum(googlephotostest.Album{Key: "AF1QipShort", Title: "Short", Items: items(4), PageSize: 2})
+
+	album, err := googlephotos.ScrapeAlbum(srv.Client(logger), srv.ShortURL("AF1QipShort"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if album.Title != "Short" || len(album.Photos) != 4 {
+		t.Errorf("got %q with %d photos", album.Title, len(album.Photos))
+	}
+	if srv.Requests("short") != 1 || srv.Requests("album") != 1 {
+		t.Errorf("short = %d, album = %d requests", srv.Requests("short"), srv.Requests("album"))
+	}
+}
+
+func TestScrapeAlbumNotFound(t *testing.T) {
+	srv := googlephotostest.NewServer()
+	defer srv.Close()
+	url := srv.AddAlbum(googlephotostest.Album{Key: "AF1QipPrivate", AuthKey: "secret", Items: items(1)})
+
+	if _, err := googlephotos.ScrapeAlbum(srv.Client(logger), url[:len(url)-len("?key=secret")]); err == nil {
+		t.Error("expected an error without the auth key")
+	}
+}
+
+func TestRateLimitRetry(t *testing.T) {
+	srv := googlephotostest.NewServer()
+	defer srv.Close()
+	url := srv.AddAlbum(googlephotostest.Album{Key: "AF1QipRetry", Title: "Retry", Items: items(2)})
+
+	client := srv.Client(logger)
+	var retried []int
+	client.OnRetry = func(status int) { retried = append(retried, status) }
+	srv.FailNext(2, http.StatusTooManyRequests)
+
+	album, err := googlephotos.ScrapeAlbum(client, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(album.Photos) != 2 {
+		t.Errorf("got %d photos", len(album.Photos))
+	}
+	if len(retried) != 2 || retried[0] != http.StatusTooManyRequests {
+		t.Errorf("retries = %v, want two 429s", retried)
+	}
+	if got := srv.Requests("album"); got != 3 {
+		t.Errorf("album requests = %d, want 3", got)
+	}
+}
+
+func download(t *testing.T, srv *googlephotostest.Server, item googlephotostest.Item, opts source.DownloadOptions) *source.Media {
+	t.Helper()
+	url := srv.AddAlbum(googlephotostest.Album{Key: "AF1Qip" + item.ID, Items: []googlephotostest.Item{item}})
+	src := googlephotos.NewSource(srv.Client(logger))
+	album, err := src.FetchAlbum(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	media, err := src.Download(album.Items[0], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return media
+}
+
+func readAll(t *testing.T, m *source.Media) []byte {
+	t.Helper()
+	defer m.Body.Close()
+	data, err := io.ReadAll(m.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)) != m.Size {
+		t.Errorf("size = %d, read %d bytes", m.Size, len(data))
+	}
+	return data
+}
+
+func TestDownload(t *testing.T) {
+	srv := googlephotostest.NewServer()
+	defer srv.Close()
+	photo := items(1)[0]
+	video := googlephotostest.Item{ID: "video", ContentType: "video/mp4", Data: []byte("mp4-data"), TakenAt: time.Now()}
+
+	m := download(t, srv, photo, source.DownloadOptions{})
+	if m.Ext != ".jpg" || m.IsVideo || m.Checksum != "" {
+		t.Errorf("photo: ext %q, video %v, checksum %q", m.Ext, m.IsVideo, m.Checksum)
+	}
+	if data := readAll(t, m); !bytes.Equal(data, photo.Data) {
+		t.Errorf("photo data = %q", data)
+	}
+
+	m = download(t, srv, video, source.DownloadOptions{})
+	if m.Ext != ".mp4" || !m.IsVideo {
+		t.Errorf("video: ext %q, video %v", m.Ext, m.IsVideo)
+	}
+	if data := readAll(t, m); !bytes.Equal(data, video.Data) {
+		t.Errorf("video data = %q", data)
+	}
+
+	m = download(t, srv, video, source.DownloadOptions{Spool: true})
+	if m.Checksum != sha1Hex(video.Data) {
+		t.Errorf("spooled checksum = %q", m.Checksum)
+	}
+	readAll(t, m)
+}
+
+func TestDownloadChunked(t *testing.T) {
+	srv := googlephotostest.NewServer()
+	defer srv.Close()
+	srv.Chunked = true
+	photo := items(1)[0]
+
+	// Without Content-Length the body is spooled to disk, which also yields the checksum
+	spoolDir := t.TempDir()
+	client := srv.Client(logger)
+	client.SpoolDir = spoolDir
+	url := srv.AddAlbum(googlephotostest.Album{Key: "AF1QipChunked", Items: []googlephotostest.Item{photo}})
+	src := googlephotos.NewSource(client)
+	album, err := src.FetchAlbum(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := src.Download(album.Items[0], source.DownloadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Size != int64(len(photo.Data)) || m.Checksum != sha1Hex(photo.Data) {
+		t.Errorf("size %d, checksum %q", m.Size, m.Checksum)
+	}
+	if data := readAll(t, m); !bytes.Equal(data, photo.Data) {
+		t.Errorf("data = %q", data)
+	}
+	if entries, _ := filepath.Glob(filepath.Join(spoolDir, "*")); len(entries) != 0 {
+		t.Errorf("spool files left behind: %v", entries)
+	}
+}
+
+func TestDownloadMotionPhoto(t *testing.T) {
+	srv := googlephotostest.NewServer()
+	defer srv.Close()
+
+	// Motion part served separately on =dv, for a still flagged as motion photo in its XMP
+	still := googlephotostest.Item{ID: "motion", ContentType: "image/jpeg", Data: []byte(`jpeg<x:xmpmeta GCamera:MotionPhoto="1"/>`), MotionVideo: []byte("motion-mp4"), TakenAt: time.Now()}
+	m := download(t, srv, still, source.DownloadOptions{SplitMotion: true})
+	if m.LiveVideo == nil {
+		t.Fatal("no live video")
+	}
+	if data := readAll(t, m.LiveVideo); !bytes.Equal(data, still.MotionVideo) || !m.LiveVideo.IsVideo || m.LiveVideo.Ext != ".mp4" {
+		t.Errorf("live video %q, ext %q", data, m.LiveVideo.Ext)
+	}
+	if data := readAll(t, m); !bytes.Equal(data, still.Data) {
+		t.Errorf("still = %q", data)
+	}
+
+	// Motion part embedded as an MP4 trailer announced in the XMP
+	trailer := []byte("embedded-mp4")
+	image := []byte(fmt.Sprintf(`jpeg<x:xmpmeta GCamera:MicroVideoOffset="%d"/>end`, len(trailer)))
+	embedded := googlephotostest.Item{ID: "embedded", ContentType: "image/jpeg", Data: append(append([]byte{}, image...), trailer...), TakenAt: time.Now()}
+	m = download(t, srv, embedded, source.DownloadOptions{SplitMotion: true})
+	if m.LiveVideo == nil {
+		t.Fatal("no embedded live video")
+	}
+	if data := readAll(t, m.LiveVideo); !bytes.Equal(data, trailer) {
+		t.Errorf("embedded video = %q", data)
+	}
+	if data := readAll(t, m); !bytes.Equal(data, image) || m.Checksum != sha1Hex(image) {
+		t.Errorf("still = %q, checksum %q", data, m.Checksum)
+	}
+
+	// Plain stills have no motion part and are not probed for one
+	before := srv.Requests("media")
+	m = download(t, srv, items(1)[0], source.DownloadOptions{SplitMotion: true})
+	if m.LiveVideo != nil {
+		t.Error("plain still got a live video")
+	}
+	if got := srv.Requests("media") - before; got != 2 {
+		t.Errorf("%d media requests for a plain still, want 2 (HEAD and =d)", got)
+	}
+	readAll(t, m)
+
+	// Unflagged stills are not probed even if Google has a =dv stream
+	unflagged := googlephotostest.Item{ID: "unflagged", ContentType: "image/jpeg", Data: []byte("still-image"), MotionVideo: []byte("motion-mp4"), TakenAt: time.Now()}
+	if m = download(t, srv, unflagged, source.DownloadOptions{SplitMotion: true}); m.LiveVideo != nil {
+		t.Error("unflagged still got a live video")
+	}
+	readAll(t, m)
+}
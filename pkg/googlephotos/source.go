@@ -0,0 +1,44 @@
+package googlephotos
+
+import "warreth.dev/immich-sync/pkg/source"
+
+// SourceType is the config "source" value handled by this package
+const SourceType = "googlephotos"
+
+// Source adapts the Google Photos scraper to the provider-neutral source.Source interface
+type Source struct {
+	client *Client
+}
+
+func NewSource(client *Client) *Source {
+	return &Source{client: client}
+}
+
+// FetchAlbum scrapes a shared album and converts its photos to source items
+func (s *Source) FetchAlbum(albumURL string) (*source.Album, error) {
+	album, err := ScrapeAlbum(s.client, albumURL)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]source.Item, 0, len(album.Photos))
+	for _, p := range album.Photos {
+		items = append(items, source.Item{
+			ID:          p.ID,
+			URL:         p.URL,
+			Width:       p.Width,
+			Height:      p.Height,
+			TakenAt:     p.TakenAt,
+			Description: p.Description,
+		})
+	}
+	return &source.Album{
+		ID:    album.ID,
+		Title: album.Title,
+		Items: items,
+	}, nil
+}
+
+// Download fetches the original media for an item
+func (s *Source) Download(item source.Item, opts source.DownloadOptions) (*source.Media, error) {
+	return downloadMedia(s.client, item.URL, opts)
+}
@@ -0,0 +1,55 @@
+package googlephotos
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// spoolFile is a download buffered on disk; the file is removed when closed
+type spoolFile struct {
+	*os.File
+}
+
+func (f *spoolFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// spoolToDisk copies r into a temp file in dir (os.TempDir() if empty), rewound for reading.
+// Returns the file, its size and the hex SHA-1 of its content.
+func spoolToDisk(dir string, r io.Reader) (*spoolFile, int64, string, error) {
+	f, err := os.CreateTemp(dir, "immich-sync-spool-*")
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to create spool file: %w", err)
+	}
+	sf := &spoolFile{File: f}
+	hasher := sha1.New()
+	n, err := io.Copy(io.MultiWriter(f, hasher), r)
+	if err != nil {
+		sf.Close()
+		return nil, 0, "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		sf.Close()
+		return nil, 0, "", err
+	}
+	return sf, n, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// mediaBody returns the response body with an accurate size. The body is streamed directly
+// when Content-Length is trustworthy, otherwise it is spooled to disk so memory use stays
+// constant regardless of file size. forceSpool always spools, which also yields the SHA-1 checksum
+// (empty for streamed bodies). The response body is always consumed or handed over.
+func (c *Client) mediaBody(resp *http.Response, forceSpool bool) (io.ReadCloser, int64, string, error) {
+	// Go sets ContentLength to -1 for chunked or transparently decompressed responses
+	if resp.ContentLength > 0 && !forceSpool {
+		return resp.Body, resp.ContentLength, "", nil
+	}
+	defer resp.Body.Close()
+	return spoolToDisk(c.SpoolDir, resp.Body)
+}
@@ -12,10 +12,11 @@ import (
 )
 
 type Album struct {
-	AlbumName string `json:"albumName"`
-	Id        string `json:"id"`
-	OwnerId   string `json:"ownerId"`
-	Assets    []struct {
+	AlbumName  string `json:"albumName"`
+	Id         string `json:"id"`
+	OwnerId    string `json:"ownerId"`
+	AssetCount int    `json:"assetCount"`
+	Assets     []struct {
 		Id               string `json:"id"`
 		OriginalFileName string `json:"originalFileName"`
 		OriginalMimeType string `json:"originalMimeType"`
@@ -26,6 +27,10 @@ type Client struct {
 	APIURL string
 	APIKey string
 	Client *http.Client
+
+	// OnError, if set, is called for every failed API call with the endpoint (see Endpoint)
+	// and the HTTP status code, or 0 if no response was received
+	OnError func(method, endpoint string, status int)
 }
 
 func NewClient(apiURL, apiKey string) *Client {
@@ -113,8 +118,74 @@ func (c *Client) AddAssetsToAlbum(albumId string, assetIds []string) error {
 	return nil
 }
 
+// RemoveAssetsFromAlbum removes assets from an album without deleting them
+func (c *Client) RemoveAssetsFromAlbum(albumId string, assetIds []string) error {
+	const batchSize = 100
+	for i := 0; i < len(assetIds); i += batchSize {
+		end := i + batchSize
+		if end > len(assetIds) {
+			end = len(assetIds)
+		}
+
+		payload := map[string]interface{}{"ids": assetIds[i:end]}
+		jsonPayload, _ := json.Marshal(payload)
+		_, err := c.request("DELETE", fmt.Sprintf("albums/%s/assets", albumId), jsonPayload, "")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAlbumsForAsset lists all albums that contain the given asset
+func (c *Client) GetAlbumsForAsset(assetId string) ([]Album, error) {
+	body, err := c.request("GET", fmt.Sprintf("albums?assetId=%s", assetId), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var albums []Album
+	err = json.Unmarshal(body, &albums)
+	return albums, err
+}
+
+// TrashAssets moves assets to the Immich trash (they can still be restored)
+func (c *Client) TrashAssets(assetIds []string) error {
+	if len(assetIds) == 0 {
+		return nil
+	}
+	payload := map[string]interface{}{"ids": assetIds, "force": false}
+	jsonPayload, _ := json.Marshal(payload)
+	_, err := c.request("DELETE", "assets", jsonPayload, "")
+	return err
+}
+
+// DeleteAssets permanently deletes assets, skipping the trash
+func (c *Client) DeleteAssets(assetIds []string) error {
+	if len(assetIds) == 0 {
+		return nil
+	}
+	payload := map[string]interface{}{"ids": assetIds, "force": true}
+	jsonPayload, _ := json.Marshal(payload)
+	_, err := c.request("DELETE", "assets", jsonPayload, "")
+	return err
+}
+
+// RestoreAssets moves assets out of the Immich trash
+func (c *Client) RestoreAssets(assetIds []string) error {
+	if len(assetIds) == 0 {
+		return nil
+	}
+	jsonPayload, _ := json.Marshal(map[string]interface{}{"ids": assetIds})
+	_, err := c.request("POST", "trash/restore/assets", jsonPayload, "")
+	return err
+}
 
 func (c *Client) requestWithReader(method string, path string, bodyReader io.Reader, contentType string) ([]byte, error) {
+	return c.requestWithHeaders(method, path, bodyReader, contentType, nil)
+}
+
+// requestWithHeaders performs an API call with additional request headers
+func (c *Client) requestWithHeaders(method string, path string, bodyReader io.Reader, contentType string, headers map[string]string) ([]byte, error) {
 	url := fmt.Sprintf("%s/%s", c.APIURL, path)
 
 	req, err := http.NewRequest(method, url, bodyReader)
@@ -129,26 +200,78 @@ func (c *Client) requestWithReader(method string, path string, bodyReader io.Rea
 		req.Header.Add("Content-Type", "application/json")
 	}
 	req.Header.Add("x-api-key", c.APIKey)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
 
 	res, err := c.Client.Do(req)
 	if err != nil {
+		c.reportError(method, path, 0)
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
+		c.reportError(method, path, 0)
 		return nil, err
 	}
 
 	if res.StatusCode >= 400 {
+		c.reportError(method, path, res.StatusCode)
 		return body, fmt.Errorf("API error: %s - %s", res.Status, string(body))
 	}
 
 	return body, nil
 }
 
+func (c *Client) reportError(method, path string, status int) {
+	if c.OnError != nil {
+		c.OnError(method, Endpoint(path), status)
+	}
+}
+
+// Endpoint reduces a request path to its route for low-cardinality labels:
+// the query is dropped and IDs are replaced, e.g. "albums/<uuid>/assets" -> "albums/{id}/assets"
+func Endpoint(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.ContainsAny(part, "0123456789") {
+			parts[i] = "{id}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// AssetUpload describes a single asset upload
+type AssetUpload struct {
+	Reader      io.Reader
+	Filename    string
+	Size        int64
+	CreatedAt   time.Time
+	Description string
+	Checksum    string // Optional hex SHA-1, lets Immich reject duplicates before reading the body
+	Sidecar     []byte // Optional XMP sidecar, sent as sidecarData
+}
+
 func (c *Client) UploadAssetStream(reader io.Reader, filename string, size int64, createdAt time.Time, description string) (string, bool, error) {
+	return c.UploadAsset(AssetUpload{
+		Reader:      reader,
+		Filename:    filename,
+		Size:        size,
+		CreatedAt:   createdAt,
+		Description: description,
+	})
+}
+
+// UploadAsset streams an asset to Immich as multipart form data.
+// Returns the asset ID and whether Immich reported it as a duplicate.
+func (c *Client) UploadAsset(u AssetUpload) (string, bool, error) {
+	reader, filename, size, createdAt, description := u.Reader, u.Filename, u.Size, u.CreatedAt, u.Description
+
 	pr, pw := io.Pipe()
 	multipartWriter := multipart.NewWriter(pw)
 
@@ -172,16 +295,38 @@ func (c *Client) UploadAssetStream(reader io.Reader, filename string, size int64
 			_ = multipartWriter.WriteField("description", description)
 		}
 
+		if len(u.Sidecar) > 0 {
+			sidecarPart, err := multipartWriter.CreateFormFile("sidecarData", filename+".xmp")
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := sidecarPart.Write(u.Sidecar); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+
 		part, err := multipartWriter.CreateFormFile("assetData", filename)
 		if err != nil {
+			pw.CloseWithError(err)
 			return
 		}
+		// Abort the request on read errors instead of uploading a truncated file
 		if _, err := io.Copy(part, reader); err != nil {
+			pw.CloseWithError(err)
 			return
 		}
 	}()
 
-	resp, err := c.requestWithReader("POST", "assets", pr, multipartWriter.FormDataContentType())
+	var headers map[string]string
+	if u.Checksum != "" {
+		headers = map[string]string{"x-immich-checksum": u.Checksum}
+	}
+
+	resp, err := c.requestWithHeaders("POST", "assets", pr, multipartWriter.FormDataContentType(), headers)
+	// Unblock the multipart writer if the server answered before reading the whole body
+	pr.Close()
 	if err != nil {
 		return "", false, err
 	}
@@ -193,6 +338,9 @@ func (c *Client) UploadAssetStream(reader io.Reader, filename string, size int64
 	if d, ok := res["duplicate"].(bool); ok && d {
 		isDup = true
 	}
+	if status, ok := res["status"].(string); ok && status == "duplicate" {
+		isDup = true
+	}
 
 	if id, ok := res["id"].(string); ok {
 		return id, isDup, nil
@@ -206,6 +354,52 @@ func (c *Client) UploadAssetStream(reader io.Reader, filename string, size int64
 	return "", false, fmt.Errorf("upload successful but no ID returned (response: %s)", string(resp))
 }
 
+// SetLivePhotoVideo links a video asset to a still image so Immich plays it as a live photo
+func (c *Client) SetLivePhotoVideo(assetId, videoId string) error {
+	jsonPayload, _ := json.Marshal(map[string]string{"livePhotoVideoId": videoId})
+	_, err := c.request("PUT", fmt.Sprintf("assets/%s", assetId), jsonPayload, "")
+	return err
+}
+
+// BulkCheckResult is Immich's verdict for one checksum of a bulk upload check
+type BulkCheckResult struct {
+	Id        string `json:"id"`
+	Action    string `json:"action"` // "accept" or "reject"
+	Reason    string `json:"reason"` // e.g. "duplicate"
+	AssetId   string `json:"assetId"`
+	IsTrashed bool   `json:"isTrashed"`
+}
+
+// BulkUploadCheck asks Immich which checksums already exist.
+// checksums maps a caller-chosen ID to a hex SHA-1; results are keyed by that ID.
+func (c *Client) BulkUploadCheck(checksums map[string]string) (map[string]BulkCheckResult, error) {
+	type checkItem struct {
+		Id       string `json:"id"`
+		Checksum string `json:"checksum"`
+	}
+	assets := make([]checkItem, 0, len(checksums))
+	for id, sum := range checksums {
+		assets = append(assets, checkItem{Id: id, Checksum: sum})
+	}
+	jsonPayload, _ := json.Marshal(map[string]interface{}{"assets": assets})
+
+	body, err := c.request("POST", "assets/bulk-upload-check", jsonPayload, "")
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Results []BulkCheckResult `json:"results"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse bulk upload check response: %w", err)
+	}
+	results := make(map[string]BulkCheckResult, len(resp.Results))
+	for _, r := range resp.Results {
+		results[r.Id] = r
+	}
+	return results, nil
+}
+
 func (c *Client) GetUser() (string, string, error) {
 	body, err := c.request("GET", "users/me", nil, "")
 	if err != nil {
@@ -220,17 +414,33 @@ func (c *Client) GetUser() (string, string, error) {
 }
 
 // SearchAssetsByDevice fetches all assets uploaded by the given deviceId using paginated metadata search.
-// Returns a map of originalFileName (without extension) -> asset ID for O(1) lookups.
+// Returns a map of originalFileName (without extension) -> asset ID for O(1) lookups. Trashed assets are excluded.
 func (c *Client) SearchAssetsByDevice(deviceId string) (map[string]string, error) {
+	return c.searchByDevice(map[string]interface{}{"deviceId": deviceId})
+}
+
+// SearchTrashedAssetsByDevice is SearchAssetsByDevice for the assets of deviceId that are in the trash
+func (c *Client) SearchTrashedAssetsByDevice(deviceId string) (map[string]string, error) {
+	return c.searchByDevice(map[string]interface{}{
+		"deviceId":     deviceId,
+		"withDeleted":  true,
+		"trashedAfter": time.Unix(0, 0).UTC().Format(time.RFC3339),
+	})
+}
+
+// searchByDevice pages through a metadata search and maps originalFileName (without extension) -> asset ID
+func (c *Client) searchByDevice(filter map[string]interface{}) (map[string]string, error) {
 	result := make(map[string]string)
 	page := 1
 	pageSize := 1000
 
 	for {
 		payload := map[string]interface{}{
-			"deviceId": deviceId,
-			"page":     page,
-			"size":     pageSize,
+			"page": page,
+			"size": pageSize,
+		}
+		for k, v := range filter {
+			payload[k] = v
 		}
 		jsonPayload, _ := json.Marshal(payload)
 
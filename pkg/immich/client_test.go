@@ -0,0 +1,23 @@
+package immich
+
+import "testing"
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"albums", "albums"},
+		{"albums?shared=false", "albums"},
+		{"albums/0d3f5b6e-7c1a-4a8e-9f3b-2c5d8e1f4a7b", "albums/{id}"},
+		{"albums/0d3f5b6e-7c1a-4a8e-9f3b-2c5d8e1f4a7b/assets", "albums/{id}/assets"},
+		{"assets/0d3f5b6e-7c1a-4a8e-9f3b-2c5d8e1f4a7b/original?key=abc", "assets/{id}/original"},
+		{"search/metadata", "search/metadata"},
+		{"trash/restore/assets", "trash/restore/assets"},
+	}
+	for _, tt := range tests {
+		if got := Endpoint(tt.path); got != tt.want {
+			t.Errorf("Endpoint(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,585 @@
+// Package immichtest provides an in-memory fake of the Immich API endpoints used by
+// immich.Client, for end-to-end tests of the sync logic without a running Immich.
+package immichtest
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// APIKey is the only key accepted by the fake server
+const APIKey = "immichtest-api-key"
+
+// Asset is an asset stored by the fake server
+type Asset struct {
+	Id               string
+	OriginalFileName string
+	DeviceId         string
+	DeviceAssetId    string
+	Checksum         string // hex SHA-1 of the uploaded data
+	Size             int64
+	FileCreatedAt    time.Time
+	Description      string
+	HasSidecar       bool
+	LivePhotoVideoId string
+	IsTrashed        bool
+}
+
+// Album is an album stored by the fake server
+type Album struct {
+	Id        string
+	AlbumName string
+	AssetIds  []string
+}
+
+// Server is a fake Immich API with in-memory state. Point immich.NewClient at APIURL().
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	nextId   int
+	assets   map[string]*Asset
+	albums   map[string]*Album
+	failures map[string][]int // route -> queued status codes
+	calls    map[string]int   // route -> number of calls
+}
+
+// NewServer starts a fake Immich server. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		assets:   make(map[string]*Asset),
+		albums:   make(map[string]*Album),
+		failures: make(map[string][]int),
+		calls:    make(map[string]int),
+	}
+	mux := http.NewServeMux()
+	s.handle(mux, "GET /api/users/me", s.handleUser)
+	s.handle(mux, "GET /api/albums", s.handleListAlbums)
+	s.handle(mux, "POST /api/albums", s.handleCreateAlbum)
+	s.handle(mux, "GET /api/albums/{id}", s.handleGetAlbum)
+	s.handle(mux, "PUT /api/albums/{id}/assets", s.handleAddToAlbum)
+	s.handle(mux, "DELETE /api/albums/{id}/assets", s.handleRemoveFromAlbum)
+	s.handle(mux, "POST /api/assets", s.handleUpload)
+	s.handle(mux, "DELETE /api/assets", s.handleDeleteAssets)
+	s.handle(mux, "PUT /api/assets/{id}", s.handleUpdateAsset)
+	s.handle(mux, "POST /api/assets/bulk-upload-check", s.handleBulkCheck)
+	s.handle(mux, "POST /api/search/metadata", s.handleSearchMetadata)
+	s.handle(mux, "POST /api/trash/restore/assets", s.handleRestore)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// APIURL returns the base URL to pass to immich.NewClient
+func (s *Server) APIURL() string {
+	return s.URL + "/api"
+}
+
+// FailNext makes the next n calls of route (e.g. "PUT /api/albums/{id}/assets") answer with status
+func (s *Server) FailNext(route string, n int, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures[route] = append(s.failures[route], status)
+	}
+}
+
+// Calls returns how often route was called, including failed calls
+func (s *Server) Calls(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[route]
+}
+
+// AddAsset seeds an asset (e.g. one backed up from a phone) and returns its ID
+func (s *Server) AddAsset(a Asset) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a.Id == "" {
+		a.Id = s.newId("asset")
+	}
+	s.assets[a.Id] = &a
+	return a.Id
+}
+
+// AddAlbum seeds an album and returns its ID
+func (s *Server) AddAlbum(name string, assetIds ...string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.newId("album")
+	s.albums[id] = &Album{Id: id, AlbumName: name, AssetIds: append([]string(nil), assetIds...)}
+	return id
+}
+
+// Trash moves an asset to the trash, as if the user deleted it
+func (s *Server) Trash(assetId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.assets[assetId]; ok {
+		a.IsTrashed = true
+	}
+}
+
+// Assets returns a snapshot of all assets, sorted by ID
+func (s *Server) Assets() []Asset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Asset, 0, len(s.assets))
+	for _, a := range s.assets {
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Id < out[j].Id })
+	return out
+}
+
+// Albums returns a snapshot of all albums, sorted by ID
+func (s *Server) Albums() []Album {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Album, 0, len(s.albums))
+	for _, a := range s.albums {
+		c := *a
+		c.AssetIds = append([]string(nil), a.AssetIds...)
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Id < out[j].Id })
+	return out
+}
+
+// newId returns a fresh ID; the caller must hold s.mu
+func (s *Server) newId(kind string) string {
+	s.nextId++
+	return fmt.Sprintf("%s-%04d", kind, s.nextId)
+}
+
+// handle registers a route with API key checking, call counting and injected failures
+func (s *Server) handle(mux *http.ServeMux, route string, h http.HandlerFunc) {
+	mux.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.calls[route]++
+		status := 0
+		if queue := s.failures[route]; len(queue) > 0 {
+			status = queue[0]
+			s.failures[route] = queue[1:]
+		}
+		s.mu.Unlock()
+
+		if r.Header.Get("x-api-key") != APIKey {
+			writeError(w, http.StatusUnauthorized, "Invalid API key")
+			return
+		}
+		if status != 0 {
+			writeError(w, status, "injected failure")
+			return
+		}
+		h(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"message": message, "statusCode": status})
+}
+
+func decodeIds(r *http.Request) ([]string, bool) {
+	var body struct {
+		Ids   []string `json:"ids"`
+		Force bool     `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, false
+	}
+	return body.Ids, body.Force
+}
+
+// assetJSON renders an asset like Immich's AssetResponseDto (relevant fields only)
+func assetJSON(a *Asset) map[string]interface{} {
+	return map[string]interface{}{
+		"id":               a.Id,
+		"originalFileName": a.OriginalFileName,
+		"originalMimeType": "application/octet-stream",
+		"deviceId":         a.DeviceId,
+		"deviceAssetId":    a.DeviceAssetId,
+		"checksum":         a.Checksum,
+		"fileCreatedAt":    a.FileCreatedAt.Format(time.RFC3339),
+		"isTrashed":        a.IsTrashed,
+		"livePhotoVideoId": a.LivePhotoVideoId,
+	}
+}
+
+// albumJSON renders an album; trashed assets are hidden like in Immich
+func (s *Server) albumJSON(al *Album, withAssets bool) map[string]interface{} {
+	assets := []map[string]interface{}{}
+	count := 0
+	for _, id := range al.AssetIds {
+		a, ok := s.assets[id]
+		if !ok || a.IsTrashed {
+			continue
+		}
+		count++
+		if withAssets {
+			assets = append(assets, assetJSON(a))
+		}
+	}
+	return map[string]interface{}{
+		"id":         al.Id,
+		"albumName":  al.AlbumName,
+		"ownerId":    "user-1",
+		"assetCount": count,
+		"assets":     assets,
+	}
+}
+
+func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"id": "user-1", "name": "Test User"})
+}
+
+func (s *Server) handleListAlbums(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assetId := r.URL.Query().Get("assetId")
+	out := []map[string]interface{}{}
+	ids := make([]string, 0, len(s.albums))
+	for id := range s.albums {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		al := s.albums[id]
+		if assetId != "" && !contains(al.AssetIds, assetId) {
+			continue
+		}
+		out = append(out, s.albumJSON(al, false))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleCreateAlbum(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		AlbumName string   `json:"albumName"`
+		AssetIds  []string `json:"assetIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.AlbumName == "" {
+		writeError(w, http.StatusBadRequest, "albumName is required")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.newId("album")
+	al := &Album{Id: id, AlbumName: body.AlbumName, AssetIds: body.AssetIds}
+	s.albums[id] = al
+	writeJSON(w, http.StatusCreated, s.albumJSON(al, true))
+}
+
+func (s *Server) handleGetAlbum(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	al, ok := s.albums[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Not found or no album.read access")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.albumJSON(al, true))
+}
+
+func (s *Server) handleAddToAlbum(w http.ResponseWriter, r *http.Request) {
+	ids, _ := decodeIds(r)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	al, ok := s.albums[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Not found or no albumAsset.create access")
+		return
+	}
+	results := []map[string]interface{}{}
+	for _, id := range ids {
+		res := map[string]interface{}{"id": id, "success": false}
+		switch {
+		case s.assets[id] == nil:
+			res["error"] = "not_found"
+		case contains(al.AssetIds, id):
+			res["error"] = "duplicate"
+		default:
+			al.AssetIds = append(al.AssetIds, id)
+			res["success"] = true
+		}
+		results = append(results, res)
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) handleRemoveFromAlbum(w http.ResponseWriter, r *http.Request) {
+	ids, _ := decodeIds(r)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	al, ok := s.albums[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Not found or no albumAsset.delete access")
+		return
+	}
+	results := []map[string]interface{}{}
+	for _, id := range ids {
+		res := map[string]interface{}{"id": id, "success": contains(al.AssetIds, id)}
+		al.AssetIds = remove(al.AssetIds, id)
+		results = append(results, res)
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handleUpload stores an uploaded asset, answering duplicates by checksum like Immich
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	// Early duplicate detection via the checksum header, before reading the body
+	if sum := r.Header.Get("x-immich-checksum"); sum != "" {
+		s.mu.Lock()
+		dup := s.findByChecksum(sum)
+		s.mu.Unlock()
+		if dup != nil {
+			writeJSON(w, http.StatusOK, map[string]string{"id": dup.Id, "status": "duplicate"})
+			return
+		}
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	file, header, err := r.FormFile("assetData")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "assetData is required")
+		return
+	}
+	defer file.Close()
+	hasher := sha1.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	_, _, sidecarErr := r.FormFile("sidecarData")
+	createdAt, _ := time.Parse(time.RFC3339, r.FormValue("fileCreatedAt"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if dup := s.findByChecksum(checksum); dup != nil {
+		writeJSON(w, http.StatusOK, map[string]string{"id": dup.Id, "status": "duplicate"})
+		return
+	}
+	a := &Asset{
+		Id:               s.newId("asset"),
+		OriginalFileName: header.Filename,
+		DeviceId:         r.FormValue("deviceId"),
+		DeviceAssetId:    r.FormValue("deviceAssetId"),
+		Checksum:         checksum,
+		Size:             size,
+		FileCreatedAt:    createdAt,
+		Description:      r.FormValue("description"),
+		HasSidecar:       sidecarErr == nil,
+	}
+	s.assets[a.Id] = a
+	writeJSON(w, http.StatusCreated, map[string]string{"id": a.Id, "status": "created"})
+}
+
+// findByChecksum accepts hex or base64 SHA-1 like Immich; the caller must hold s.mu
+func (s *Server) findByChecksum(sum string) *Asset {
+	for _, a := range s.assets {
+		if a.Checksum == sum || hexToBase64(a.Checksum) == sum {
+			return a
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleDeleteAssets(w http.ResponseWriter, r *http.Request) {
+	ids, force := decodeIds(r)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		a, ok := s.assets[id]
+		if !ok {
+			continue
+		}
+		if force {
+			delete(s.assets, id)
+			for _, al := range s.albums {
+				al.AssetIds = remove(al.AssetIds, id)
+			}
+		} else {
+			a.IsTrashed = true
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUpdateAsset(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		LivePhotoVideoId *string `json:"livePhotoVideoId"`
+		Description      *string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.assets[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Not found or no asset.update access")
+		return
+	}
+	if body.LivePhotoVideoId != nil {
+		if _, ok := s.assets[*body.LivePhotoVideoId]; !ok && *body.LivePhotoVideoId != "" {
+			writeError(w, http.StatusBadRequest, "Live photo video not found")
+			return
+		}
+		a.LivePhotoVideoId = *body.LivePhotoVideoId
+	}
+	if body.Description != nil {
+		a.Description = *body.Description
+	}
+	writeJSON(w, http.StatusOK, assetJSON(a))
+}
+
+func (s *Server) handleBulkCheck(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Assets []struct {
+			Id       string `json:"id"`
+			Checksum string `json:"checksum"`
+		} `json:"assets"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := []map[string]interface{}{}
+	for _, item := range body.Assets {
+		res := map[string]interface{}{"id": item.Id, "action": "accept"}
+		if dup := s.findByChecksum(item.Checksum); dup != nil {
+			res["action"] = "reject"
+			res["reason"] = "duplicate"
+			res["assetId"] = dup.Id
+			res["isTrashed"] = dup.IsTrashed
+		}
+		results = append(results, res)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// handleSearchMetadata implements the paginated metadata search (deviceId, withDeleted, trashedAfter)
+func (s *Server) handleSearchMetadata(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		DeviceId     string     `json:"deviceId"`
+		Page         int        `json:"page"`
+		Size         int        `json:"size"`
+		WithDeleted  bool       `json:"withDeleted"`
+		TrashedAfter *time.Time `json:"trashedAfter"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page < 1 {
+		body.Page = 1
+	}
+	if body.Size < 1 || body.Size > 1000 {
+		body.Size = 250
+	}
+
+	s.mu.Lock()
+	var matches []*Asset
+	for _, a := range s.assets {
+		if body.DeviceId != "" && a.DeviceId != body.DeviceId {
+			continue
+		}
+		onlyTrashed := body.TrashedAfter != nil
+		if (a.IsTrashed && !body.WithDeleted && !onlyTrashed) || (onlyTrashed && !a.IsTrashed) {
+			continue
+		}
+		matches = append(matches, a)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Id < matches[j].Id })
+	start := (body.Page - 1) * body.Size
+	end := start + body.Size
+	if start > len(matches) {
+		start = len(matches)
+	}
+	if end > len(matches) {
+		end = len(matches)
+	}
+	items := []map[string]interface{}{}
+	for _, a := range matches[start:end] {
+		items = append(items, assetJSON(a))
+	}
+	s.mu.Unlock()
+
+	var nextPage interface{}
+	if end < len(matches) {
+		nextPage = strconv.Itoa(body.Page + 1)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"albums": map[string]interface{}{"items": []interface{}{}, "total": 0, "count": 0},
+		"assets": map[string]interface{}{
+			"items":    items,
+			"total":    len(matches),
+			"count":    len(items),
+			"nextPage": nextPage,
+		},
+	})
+}
+
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	ids, _ := decodeIds(r)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, id := range ids {
+		if a, ok := s.assets[id]; ok && a.IsTrashed {
+			a.IsTrashed = false
+			count++
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"count": count})
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, v string) []string {
+	out := list[:0]
+	for _, x := range list {
+		if x != v {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func hexToBase64(h string) string {
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,285 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Embedded date sources reported by ReadDate
+const (
+	DateSourceExif      = "exif"
+	DateSourceQuickTime = "quicktime"
+	DateSourcePNG       = "png"
+)
+
+// maxScanBoxes bounds how many boxes/chunks/segments are walked in a file
+const maxScanBoxes = 4096
+
+// maxPNGChunk bounds the size of a PNG text or EXIF chunk that is read into memory;
+// larger chunks are skipped rather than trusted
+const maxPNGChunk = 64 * 1024
+
+// minYear is the earliest capture year accepted from embedded metadata
+const minYear = 1900
+
+// quickTimeEpoch is the zero point of QuickTime/MP4 timestamps
+var quickTimeEpoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ReadDate recovers the capture date embedded in a downloaded file: EXIF DateTimeOriginal
+// (with its offset) for JPEG, QuickTime mvhd creation_time for MP4/MOV, and tEXt/eXIf for PNG.
+// Returns the date and which kind of metadata it came from.
+func ReadDate(r io.ReaderAt, size int64) (time.Time, string, bool) {
+	head := make([]byte, 12)
+	n, _ := r.ReadAt(head, 0)
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, markerSOI}):
+		if t, ok := jpegDate(r, size); ok {
+			return t, DateSourceExif, true
+		}
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		if t, ok := pngDate(r, size); ok {
+			return t, DateSourcePNG, true
+		}
+	case len(head) >= 8 && (string(head[4:8]) == "ftyp" || string(head[4:8]) == "moov" || string(head[4:8]) == "wide" || string(head[4:8]) == "mdat"):
+		if t, ok := quickTimeDate(r, 0, size, 0); ok {
+			return t, DateSourceQuickTime, true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// jpegDate walks JPEG segments up to the image data looking for the EXIF APP1 segment
+func jpegDate(r io.ReaderAt, size int64) (time.Time, bool) {
+	off := int64(2)
+	hdr := make([]byte, 4)
+	for i := 0; i < maxScanBoxes && off+4 <= size; i++ {
+		if _, err := r.ReadAt(hdr, off); err != nil || hdr[0] != 0xFF {
+			return time.Time{}, false
+		}
+		marker := hdr[1]
+		length := int64(binary.BigEndian.Uint16(hdr[2:4]))
+		if marker == 0xDA || length < 2 { // start of scan: no more metadata
+			return time.Time{}, false
+		}
+		if marker == markerAPP1 && length > int64(len(exifHeader))+2 {
+			payload := make([]byte, length-2)
+			if _, err := r.ReadAt(payload, off+4); err != nil {
+				return time.Time{}, false
+			}
+			if bytes.HasPrefix(payload, exifHeader) {
+				return exifDate(payload[len(exifHeader):])
+			}
+		}
+		off += 2 + length
+	}
+	return time.Time{}, false
+}
+
+// exifDate extracts DateTimeOriginal (or DateTime) from a TIFF structure
+func exifDate(tiff []byte) (time.Time, bool) {
+	if len(tiff) < 8 {
+		return time.Time{}, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return time.Time{}, false
+	}
+
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:8]))
+	var original, offset string
+	if ptr, ok := ifd0[tagExifIFD]; ok && len(ptr) >= 4 {
+		exif := readIFD(tiff, order, order.Uint32(ptr))
+		original = asciiValue(exif[tagDateTimeOriginal])
+		offset = asciiValue(exif[tagOffsetTimeOriginal])
+	}
+	if original == "" {
+		original = asciiValue(ifd0[tagDateTime])
+	}
+	if original == "" {
+		return time.Time{}, false
+	}
+
+	// Without OffsetTimeOriginal the time is local to the camera; treat it as UTC
+	if offset != "" {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", original+offset); err == nil {
+			return t, plausibleDate(t)
+		}
+	}
+	t, err := time.Parse("2006:01:02 15:04:05", original)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, plausibleDate(t)
+}
+
+// plausibleDate rejects decoded dates that cannot be a capture date, such as a 0000:00:00
+// placeholder or a corrupt timestamp far in the future
+func plausibleDate(t time.Time) bool {
+	return t.Year() >= minYear && t.Year() <= time.Now().Year()+1
+}
+
+const tagDateTime = 0x0132
+
+// readIFD returns the raw value bytes of every entry of the IFD at offset
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) map[uint16][]byte {
+	entries := make(map[uint16][]byte)
+	if int(offset)+2 > len(tiff) {
+		return entries
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		e := int(offset) + 2 + i*12
+		if e+12 > len(tiff) {
+			break
+		}
+		tag := order.Uint16(tiff[e:])
+		typ := order.Uint16(tiff[e+2:])
+		n := int(order.Uint32(tiff[e+4:]))
+		var unit int
+		switch typ {
+		case typeASCII, 1, 7: // ASCII, BYTE, UNDEFINED
+			unit = 1
+		case typeLONG:
+			unit = 4
+		default:
+			continue
+		}
+		length := n * unit
+		if length <= 4 {
+			entries[tag] = tiff[e+8 : e+8+length]
+			continue
+		}
+		start := int(order.Uint32(tiff[e+8:]))
+		if start < 0 || start+length > len(tiff) {
+			continue
+		}
+		entries[tag] = tiff[start : start+length]
+	}
+	return entries
+}
+
+func asciiValue(b []byte) string {
+	return strings.TrimSpace(strings.TrimRight(string(b), "\x00"))
+}
+
+// quickTimeDate walks ISO BMFF boxes in [start, end) looking for moov/mvhd
+func quickTimeDate(r io.ReaderAt, start, end int64, depth int) (time.Time, bool) {
+	hdr := make([]byte, 16)
+	off := start
+	for i := 0; i < maxScanBoxes && off+8 <= end; i++ {
+		if _, err := r.ReadAt(hdr[:8], off); err != nil {
+			return time.Time{}, false
+		}
+		boxSize := int64(binary.BigEndian.Uint32(hdr[:4]))
+		boxType := string(hdr[4:8])
+		headerLen := int64(8)
+		switch boxSize {
+		case 0: // box extends to end of file
+			boxSize = end - off
+		case 1: // 64-bit size follows
+			if _, err := r.ReadAt(hdr[8:16], off+8); err != nil {
+				return time.Time{}, false
+			}
+			boxSize = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			headerLen = 16
+		}
+		if boxSize < headerLen || off+boxSize > end {
+			return time.Time{}, false
+		}
+
+		switch {
+		case boxType == "moov" && depth == 0:
+			return quickTimeDate(r, off+headerLen, off+boxSize, depth+1)
+		case boxType == "mvhd" && depth == 1:
+			return mvhdDate(r, off+headerLen, boxSize-headerLen)
+		}
+		off += boxSize
+	}
+	return time.Time{}, false
+}
+
+// mvhdDate decodes creation_time from a movie header box (version 0 or 1)
+func mvhdDate(r io.ReaderAt, off, length int64) (time.Time, bool) {
+	buf := make([]byte, 12)
+	if length < 12 {
+		return time.Time{}, false
+	}
+	if _, err := r.ReadAt(buf, off); err != nil {
+		return time.Time{}, false
+	}
+	var secs uint64
+	if buf[0] == 1 {
+		secs = binary.BigEndian.Uint64(buf[4:12])
+	} else {
+		secs = uint64(binary.BigEndian.Uint32(buf[4:8]))
+	}
+	// Many encoders leave creation_time unset (zero)
+	if secs == 0 || secs > math.MaxInt64/uint64(time.Second) {
+		return time.Time{}, false
+	}
+	// Small values are uninitialised clocks counting from the epoch rather than capture dates
+	t := quickTimeEpoch.Add(time.Duration(secs) * time.Second)
+	return t, t.Year() > quickTimeEpoch.Year() && plausibleDate(t)
+}
+
+// pngDate looks for an eXIf chunk or a "Creation Time" tEXt chunk
+func pngDate(r io.ReaderAt, size int64) (time.Time, bool) {
+	off := int64(8)
+	hdr := make([]byte, 8)
+	for i := 0; i < maxScanBoxes && off+8 <= size; i++ {
+		if _, err := r.ReadAt(hdr, off); err != nil {
+			return time.Time{}, false
+		}
+		length := int64(binary.BigEndian.Uint32(hdr[:4]))
+		chunkType := string(hdr[4:8])
+		if chunkType == "IDAT" || chunkType == "IEND" || off+8+length > size {
+			return time.Time{}, false
+		}
+		if (chunkType == "eXIf" || chunkType == "tEXt") && length <= maxPNGChunk {
+			data := make([]byte, length)
+			if _, err := r.ReadAt(data, off+8); err != nil {
+				return time.Time{}, false
+			}
+			if chunkType == "eXIf" {
+				if t, ok := exifDate(data); ok {
+					return t, true
+				}
+			} else if key, value, ok := bytes.Cut(data, []byte{0}); ok && string(key) == "Creation Time" {
+				if t, ok := parseLooseDate(string(value)); ok && plausibleDate(t) {
+					return t, true
+				}
+			}
+		}
+		off += 12 + length // length, type, data, CRC
+	}
+	return time.Time{}, false
+}
+
+// parseLooseDate accepts the date formats commonly found in PNG "Creation Time" text
+func parseLooseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{
+		time.RFC3339,
+		time.RFC1123Z,
+		time.RFC1123,
+		"2006:01:02 15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
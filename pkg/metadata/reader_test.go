@@ -0,0 +1,94 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// exifJPEG wraps a TIFF structure in a minimal JPEG: SOI, an EXIF APP1 segment and the start of scan
+func exifJPEG(tiff []byte) []byte {
+	payload := append(append([]byte{}, exifHeader...), tiff...)
+	b := []byte{0xFF, 0xD8, 0xFF, markerAPP1}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(payload)+2))
+	b = append(b, payload...)
+	return append(b, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9)
+}
+
+// box builds an ISO BMFF box
+func box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(b, typ...), body...)
+}
+
+// mvhd builds a version 0 movie header payload with the given creation time
+func mvhd(t time.Time) []byte {
+	b := make([]byte, 100)
+	binary.BigEndian.PutUint32(b[4:8], uint32(t.Sub(quickTimeEpoch)/time.Second))
+	return b
+}
+
+// chunk builds a PNG chunk with a zero CRC, which the reader does not check
+func chunk(typ string, data []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	b = append(append(b, typ...), data...)
+	return append(b, 0, 0, 0, 0)
+}
+
+func pngFile(chunks ...[]byte) []byte {
+	return append([]byte("\x89PNG\r\n\x1a\n"), bytes.Join(chunks, nil)...)
+}
+
+func TestReadDate(t *testing.T) {
+	taken := time.Date(2023, 7, 14, 18, 30, 5, 0, time.FixedZone("", 2*3600))
+	exif := BuildExif(Info{TakenAt: taken})
+	ihdr := chunk("IHDR", make([]byte, 13))
+	iend := chunk("IEND", nil)
+	ftyp := box("ftyp", []byte("isom\x00\x00\x02\x00"))
+
+	tests := []struct {
+		name   string
+		data   []byte
+		want   time.Time
+		source string // empty if no date should be found
+	}{
+		{"jpeg exif", exifJPEG(exif), taken, DateSourceExif},
+		{"jpeg exif without offset", exifJPEG(BuildExif(Info{TakenAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)})), time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), DateSourceExif},
+		{"jpeg without exif", jpeg, time.Time{}, ""},
+		{"jpeg truncated exif", exifJPEG(exif)[:30], time.Time{}, ""},
+		{"jpeg exif truncated tiff", exifJPEG(exif[:20]), time.Time{}, ""},
+		{"jpeg exif bad byte order", exifJPEG(append([]byte("XX"), exif[2:]...)), time.Time{}, ""},
+		{"jpeg exif ifd out of range", exifJPEG([]byte{'I', 'I', 42, 0, 0xFF, 0xFF, 0, 0}), time.Time{}, ""},
+		{"jpeg exif year too early", exifJPEG(BuildExif(Info{TakenAt: time.Date(1850, 1, 1, 0, 0, 0, 0, time.UTC)})), time.Time{}, ""},
+		{"jpeg exif year in the future", exifJPEG(BuildExif(Info{TakenAt: time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC)})), time.Time{}, ""},
+		{"jpeg segment length 0", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x00, 0xFF, 0xD9}, time.Time{}, ""},
+		{"mp4 mvhd", append(ftyp, box("moov", box("mvhd", mvhd(taken)))...), taken, DateSourceQuickTime},
+		{"mp4 mvhd unset", append(ftyp, box("moov", box("mvhd", make([]byte, 100)))...), time.Time{}, ""},
+		{"mp4 mvhd year too early", append(ftyp, box("moov", box("mvhd", mvhd(quickTimeEpoch.Add(time.Hour))))...), time.Time{}, ""},
+		{"mp4 mvhd truncated", append(ftyp, box("moov", box("mvhd", []byte{0, 0, 0}))...), time.Time{}, ""},
+		{"mp4 box overruns file", append(ftyp, box("moov", box("mvhd", mvhd(taken)))[:50]...), time.Time{}, ""},
+		{"mp4 box size too small", append(ftyp, 0, 0, 0, 4, 'm', 'o', 'o', 'v'), time.Time{}, ""},
+		{"png exif", pngFile(ihdr, chunk("eXIf", exif), iend), taken, DateSourcePNG},
+		{"png creation time", pngFile(ihdr, chunk("tEXt", []byte("Creation Time\x002023-07-14T18:30:05+02:00")), iend), taken, DateSourcePNG},
+		{"png other text", pngFile(ihdr, chunk("tEXt", []byte("Comment\x002023-07-14T18:30:05+02:00")), iend), time.Time{}, ""},
+		{"png creation time unparseable", pngFile(ihdr, chunk("tEXt", []byte("Creation Time\x00yesterday")), iend), time.Time{}, ""},
+		{"png text after image data", pngFile(ihdr, chunk("IDAT", nil), chunk("tEXt", []byte("Creation Time\x002023-07-14T18:30:05Z"))), time.Time{}, ""},
+		{"png oversized chunk", pngFile(ihdr, chunk("tEXt", append([]byte("Creation Time\x002023-07-14T18:30:05Z\x00"), make([]byte, maxPNGChunk)...)), iend), time.Time{}, ""},
+		{"png chunk overruns file", pngFile(ihdr, chunk("eXIf", exif)[:20]), time.Time{}, ""},
+		{"unknown format", []byte("GIF89a"), time.Time{}, ""},
+		{"empty", nil, time.Time{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, source, ok := ReadDate(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if ok != (tt.source != "") || source != tt.source {
+				t.Fatalf("got %v from %q (ok %v), want source %q", got, source, ok, tt.source)
+			}
+			if ok && !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Info is the metadata embedded into uploaded files
+type Info struct {
+	TakenAt   time.Time
+	Caption   string
+	SourceURL string
+}
+
+const (
+	markerSOI  = 0xD8
+	markerAPP0 = 0xE0
+	markerAPP1 = 0xE1
+	markerAPPF = 0xEF
+	markerCOM  = 0xFE
+
+	// maxHeaderSegments bounds how many leading APPn/COM segments are buffered
+	maxHeaderSegments = 64
+	// maxSegmentPayload is the largest payload of a marker segment, whose length field is 16 bits
+	maxSegmentPayload = 0xFFFF - 2
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	xmpHeader  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+)
+
+// IsJPEG reports whether files with this extension can be rewritten by EmbedJPEG
+func IsJPEG(ext string) bool {
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// EmbedJPEG returns a reader yielding the JPEG from r with EXIF and XMP segments added.
+// Existing EXIF or XMP segments are never modified: if one is already present that part is
+// skipped. The added XMP carries all fields, so complete reports false only when the file
+// already had XMP and the caller should fall back to an XMP sidecar.
+// added is the number of bytes inserted. On error out still yields the unmodified file.
+func EmbedJPEG(r io.Reader, info Info) (out io.Reader, added int64, complete bool, err error) {
+	br := bufio.NewReader(r)
+	// Everything consumed while parsing is kept, so the original can be replayed on error
+	var consumed bytes.Buffer
+	tr := io.TeeReader(br, &consumed)
+	unmodified := func(err error) (io.Reader, int64, bool, error) {
+		return io.MultiReader(&consumed, br), 0, false, err
+	}
+
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(tr, soi); err != nil {
+		return unmodified(err)
+	}
+	if soi[0] != 0xFF || soi[1] != markerSOI {
+		return unmodified(fmt.Errorf("not a JPEG file"))
+	}
+
+	// Buffer the leading APPn/COM segments to find existing metadata
+	var jfif []byte
+	var segments bytes.Buffer
+	hasExif, hasXMP := false, false
+	for i := 0; i < maxHeaderSegments; i++ {
+		hdr, err := br.Peek(4)
+		if err != nil {
+			return unmodified(err)
+		}
+		marker := hdr[1]
+		if hdr[0] != 0xFF || !(marker >= markerAPP0 && marker <= markerAPPF || marker == markerCOM) {
+			break
+		}
+		// The length counts itself, so anything below 2 is corrupt
+		length := int(binary.BigEndian.Uint16(hdr[2:4]))
+		if length < 2 {
+			return unmodified(fmt.Errorf("invalid length %d of JPEG segment %#x", length, marker))
+		}
+		seg := make([]byte, 2+length)
+		if _, err := io.ReadFull(tr, seg); err != nil {
+			return unmodified(err)
+		}
+		payload := seg[4:]
+		switch {
+		case marker == markerAPP0 && i == 0:
+			jfif = seg
+			continue
+		case marker == markerAPP1 && bytes.HasPrefix(payload, exifHeader):
+			hasExif = true
+		case marker == markerAPP1 && bytes.HasPrefix(payload, xmpHeader):
+			hasXMP = true
+		}
+		segments.Write(seg)
+	}
+
+	var head bytes.Buffer
+	head.Write(soi)
+	head.Write(jfif) // JFIF must stay directly after SOI
+	if !hasExif {
+		seg, err := appSegment(markerAPP1, append(append([]byte{}, exifHeader...), BuildExif(info)...))
+		if err != nil {
+			return unmodified(err)
+		}
+		head.Write(seg)
+	}
+	head.Write(segments.Bytes())
+	if !hasXMP {
+		seg, err := appSegment(markerAPP1, append(append([]byte{}, xmpHeader...), BuildXMP(info)...))
+		if err != nil {
+			return unmodified(err)
+		}
+		head.Write(seg)
+	}
+
+	added = int64(head.Len() - 2 - len(jfif) - segments.Len())
+	return io.MultiReader(&head, br), added, !hasXMP, nil
+}
+
+// appSegment wraps payload in a JPEG marker segment
+func appSegment(marker byte, payload []byte) ([]byte, error) {
+	if len(payload) > maxSegmentPayload {
+		return nil, fmt.Errorf("metadata of %d bytes does not fit in a JPEG segment", len(payload))
+	}
+	seg := make([]byte, 4, 4+len(payload))
+	seg[0] = 0xFF
+	seg[1] = marker
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...), nil
+}
+
+// BuildXMP returns an XMP packet with capture date, caption and source URL.
+// It is used both inside JPEGs and as an upload sidecar.
+func BuildXMP(info Info) []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/">
+`)
+	if !info.TakenAt.IsZero() {
+		ts := info.TakenAt.Format(time.RFC3339)
+		fmt.Fprintf(&b, "   <exif:DateTimeOriginal>%s</exif:DateTimeOriginal>\n", ts)
+		fmt.Fprintf(&b, "   <photoshop:DateCreated>%s</photoshop:DateCreated>\n", ts)
+	}
+	if info.Caption != "" {
+		b.WriteString(`   <dc:description><rdf:Alt><rdf:li xml:lang="x-default">`)
+		xml.EscapeText(&b, []byte(info.Caption))
+		b.WriteString("</rdf:li></rdf:Alt></dc:description>\n")
+	}
+	if info.SourceURL != "" {
+		b.WriteString("   <dc:source>")
+		xml.EscapeText(&b, []byte(info.SourceURL))
+		b.WriteString("</dc:source>\n")
+	}
+	b.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
+
+// EXIF tag IDs and field types used by BuildExif
+const (
+	tagImageDescription   = 0x010E
+	tagExifIFD            = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+
+	typeASCII = 2
+	typeLONG  = 4
+)
+
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+// BuildExif returns a little-endian TIFF structure (without the "Exif" prefix) holding
+// ImageDescription and DateTimeOriginal with its offset.
+func BuildExif(info Info) []byte {
+	var ifd0, exifIFD []ifdEntry
+	if info.Caption != "" {
+		ifd0 = append(ifd0, asciiEntry(tagImageDescription, info.Caption))
+	}
+	if !info.TakenAt.IsZero() {
+		exifIFD = append(exifIFD,
+			asciiEntry(tagDateTimeOriginal, info.TakenAt.Format("2006:01:02 15:04:05")),
+			asciiEntry(tagOffsetTimeOriginal, info.TakenAt.Format("-07:00")),
+		)
+	}
+
+	const headerSize = 8
+	ifd0Size := ifdSize(ifd0, len(exifIFD) > 0)
+	if len(exifIFD) > 0 {
+		ptr := make([]byte, 4)
+		binary.LittleEndian.PutUint32(ptr, uint32(headerSize+ifd0Size))
+		ifd0 = append(ifd0, ifdEntry{tag: tagExifIFD, typ: typeLONG, count: 1, data: ptr})
+	}
+
+	var b bytes.Buffer
+	b.WriteString("II")
+	binary.Write(&b, binary.LittleEndian, uint16(42))
+	binary.Write(&b, binary.LittleEndian, uint32(headerSize))
+	writeIFD(&b, ifd0, headerSize)
+	if len(exifIFD) > 0 {
+		writeIFD(&b, exifIFD, headerSize+ifd0Size)
+	}
+	return b.Bytes()
+}
+
+func asciiEntry(tag uint16, s string) ifdEntry {
+	data := append([]byte(s), 0)
+	return ifdEntry{tag: tag, typ: typeASCII, count: uint32(len(data)), data: data}
+}
+
+// ifdSize returns the encoded size of an IFD including its out-of-line values
+func ifdSize(entries []ifdEntry, extraPointer bool) int {
+	n := len(entries)
+	if extraPointer {
+		n++
+	}
+	size := 2 + 12*n + 4
+	for _, e := range entries {
+		if len(e.data) > 4 {
+			size += len(e.data) + len(e.data)%2
+		}
+	}
+	return size
+}
+
+// writeIFD encodes an IFD at the given TIFF offset, with values larger than 4 bytes after it
+func writeIFD(b *bytes.Buffer, entries []ifdEntry, offset int) {
+	dataOffset := offset + 2 + 12*len(entries) + 4
+	var data bytes.Buffer
+	binary.Write(b, binary.LittleEndian, uint16(len(entries)))
+	for _, e := range entries {
+		binary.Write(b, binary.LittleEndian, e.tag)
+		binary.Write(b, binary.LittleEndian, e.typ)
+		binary.Write(b, binary.LittleEndian, e.count)
+		if len(e.data) <= 4 {
+			val := make([]byte, 4)
+			copy(val, e.data)
+			b.Write(val)
+			continue
+		}
+		binary.Write(b, binary.LittleEndian, uint32(dataOffset+data.Len()))
+		data.Write(e.data)
+		if len(e.data)%2 == 1 {
+			data.WriteByte(0) // keep values word-aligned
+		}
+	}
+	binary.Write(b, binary.LittleEndian, uint32(0)) // no next IFD
+	b.Write(data.Bytes())
+}
@@ -0,0 +1,62 @@
+package metadata
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// jpeg is a minimal JPEG: SOI, a JFIF APP0 segment, and the start of the image data
+var jpeg = []byte{
+	0xFF, 0xD8,
+	0xFF, 0xE0, 0x00, 0x07, 'J', 'F', 'I', 'F', 0x00,
+	0xFF, 0xDB, 0x00, 0x02,
+	0xFF, 0xD9,
+}
+
+func TestEmbedJPEG(t *testing.T) {
+	info := Info{TakenAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Caption: "Beach", SourceURL: "https://photos.app.goo.gl/x"}
+	out, added, complete, err := EmbedJPEG(bytes.NewReader(jpeg), info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(out)
+	if int64(len(data)) != int64(len(jpeg))+added || !complete {
+		t.Fatalf("got %d bytes, added %d, complete %v", len(data), added, complete)
+	}
+	if !bytes.Equal(data[:11], jpeg[:11]) {
+		t.Error("JFIF segment is not directly after SOI")
+	}
+	if !bytes.Contains(data, exifHeader) || !bytes.Contains(data, []byte("<dc:source>https://photos.app.goo.gl/x</dc:source>")) {
+		t.Error("EXIF or XMP missing")
+	}
+}
+
+func TestEmbedJPEGInvalid(t *testing.T) {
+	long := strings.Repeat("x", 70000)
+	tests := []struct {
+		name string
+		data []byte
+		info Info
+	}{
+		{"segment length 0", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x00, 0xFF, 0xD9}, Info{Caption: "a"}},
+		{"segment length 1", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01, 0xFF, 0xD9}, Info{Caption: "a"}},
+		{"truncated segment", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x01, 0x00, 'E', 'x'}, Info{Caption: "a"}},
+		{"not a JPEG", []byte("GIF89a"), Info{Caption: "a"}},
+		{"oversized caption", jpeg, Info{Caption: long}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, added, _, err := EmbedJPEG(bytes.NewReader(tt.data), tt.info)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			data, _ := io.ReadAll(out)
+			if added != 0 || !bytes.Equal(data, tt.data) {
+				t.Errorf("original not replayed: added %d, got %d of %d bytes", added, len(data), len(tt.data))
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+	items := r.Counter("items_total", "Items by outcome.", "album", "outcome")
+	items.Inc("b", "added")
+	items.Add(2, "a", "added")
+	items.Add(-1, "a", "added") // ignored
+	items.Inc("a", "failed")
+
+	g := r.Gauge("next_run_seconds", "Next run.\nSecond line with a \\ backslash.", "album")
+	g.Set(1714564800.5, `quote " back\slash`+"\nnewline")
+	g.Set(math.Inf(1), "inf")
+
+	h := r.Histogram("duration_seconds", "Sync duration.", []float64{10, 1, 5}, "album")
+	h.Observe(0.5, "a")
+	h.Observe(5, "a")
+	h.Observe(7.25, "a")
+	h.Observe(100, "a")
+
+	plain := r.Counter("plain_total", "No labels.")
+	plain.Inc()
+
+	r.Gauge("empty", "Registered without series.", "album")
+
+	var b bytes.Buffer
+	n, err := r.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP items_total Items by outcome.
+# TYPE items_total counter
+items_total{album="a",outcome="added"} 2
+items_total{album="a",outcome="failed"} 1
+items_total{album="b",outcome="added"} 1
+# HELP next_run_seconds Next run.\nSecond line with a \\ backslash.
+# TYPE next_run_seconds gauge
+next_run_seconds{album="inf"} +Inf
+next_run_seconds{album="quote \" back\\slash\nnewline"} 1.7145648005e+09
+# HELP duration_seconds Sync duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{album="a",le="1"} 1
+duration_seconds_bucket{album="a",le="5"} 2
+duration_seconds_bucket{album="a",le="10"} 3
+duration_seconds_bucket{album="a",le="+Inf"} 4
+duration_seconds_sum{album="a"} 112.75
+duration_seconds_count{album="a"} 4
+# HELP plain_total No labels.
+# TYPE plain_total counter
+plain_total 1
+# HELP empty Registered without series.
+# TYPE empty gauge
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("reported %d bytes, wrote %d", n, b.Len())
+	}
+}
+
+func TestDelete(t *testing.T) {
+	r := NewRegistry()
+	g := r.Gauge("last_run", "Last run.", "album")
+	g.Set(1, "a")
+	g.Set(2, "b")
+	g.Delete("a")
+	g.Delete("missing")
+
+	var b bytes.Buffer
+	r.WriteTo(&b)
+	if strings.Contains(b.String(), `album="a"`) || !strings.Contains(b.String(), `last_run{album="b"} 2`) {
+		t.Errorf("got\n%s", b.String())
+	}
+}
+
+func TestLabelCountMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	NewRegistry().Counter("c_total", "c", "album", "outcome").Inc("only-album")
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("c_total", "c").Inc()
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("got content type %q", ct)
+	}
+	if !strings.HasSuffix(rec.Body.String(), "c_total 1\n") {
+		t.Errorf("got\n%s", rec.Body.String())
+	}
+}
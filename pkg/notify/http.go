@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var httpClient = &http.Client{}
+
+// post sends body and fails on any non-2xx response
+func post(ctx context.Context, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// isFailure reports whether a message needs attention, for channel priorities: anything but new items
+func isFailure(m Message) bool {
+	return m.Event.Trigger != "added"
+}
+
+// Webhook POSTs {"title", "message", "event"} as JSON
+type Webhook struct {
+	URL string
+}
+
+func (w *Webhook) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"title":   m.Title,
+		"message": m.Body,
+		"event":   m.Event,
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, w.URL, body, http.Header{"Content-Type": {"application/json"}})
+}
+
+// Ntfy publishes to an ntfy topic, e.g. https://ntfy.sh/my-topic
+type Ntfy struct {
+	URL   string
+	Token string // Optional, access token
+}
+
+func (n *Ntfy) Send(ctx context.Context, m Message) error {
+	header := http.Header{
+		"Title": {m.Title},
+		"Tags":  {"camera"},
+	}
+	if isFailure(m) {
+		header.Set("Priority", "high")
+		header.Set("Tags", "warning")
+	}
+	if n.Token != "" {
+		header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return post(ctx, n.URL, []byte(m.Body), header)
+}
+
+// Gotify posts to the message API of a Gotify server
+type Gotify struct {
+	URL   string // server URL, e.g. https://gotify.example.com
+	Token string // application token
+}
+
+func (g *Gotify) Send(ctx context.Context, m Message) error {
+	priority := 5
+	if isFailure(m) {
+		priority = 8
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"title":    m.Title,
+		"message":  m.Body,
+		"priority": priority,
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, strings.TrimRight(g.URL, "/")+"/message", body, http.Header{
+		"Content-Type": {"application/json"},
+		"X-Gotify-Key": {g.Token},
+	})
+}
@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// request is what a test server received
+type request struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// recorder starts a server that records every request and answers with status
+func recorder(t *testing.T, status int) (*httptest.Server, <-chan request) {
+	t.Helper()
+	requests := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{Path: r.URL.Path, Header: r.Header, Body: body}
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			io.WriteString(w, "denied\n")
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func testMessage(trigger string) Message {
+	return Message{
+		Title: "Holiday: 2 new items",
+		Body:  "2 new, 0 skipped and 0 failed of 2 items in 1s.",
+		Event: Event{
+			Trigger:  trigger,
+			Triggers: []string{trigger},
+			Album:    "Holiday",
+			URL:      "https://photos.app.goo.gl/abc",
+			Status:   "success",
+			Total:    2,
+			New:      2,
+			Added:    2,
+			Time:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	srv, requests := recorder(t, http.StatusOK)
+	if err := (&Webhook{URL: srv.URL + "/hook"}).Send(context.Background(), testMessage("added")); err != nil {
+		t.Fatal(err)
+	}
+	r := <-requests
+	if r.Path != "/hook" || r.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("got %s with content type %q", r.Path, r.Header.Get("Content-Type"))
+	}
+	var got struct {
+		Title   string `json:"title"`
+		Message string `json:"message"`
+		Event   Event  `json:"event"`
+	}
+	if err := json.Unmarshal(r.Body, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", r.Body, err)
+	}
+	want := testMessage("added")
+	if got.Title != want.Title || got.Message != want.Body {
+		t.Errorf("got title %q and message %q", got.Title, got.Message)
+	}
+	if got.Event.Trigger != "added" || got.Event.Album != "Holiday" || got.Event.Added != 2 || !got.Event.Time.Equal(want.Event.Time) {
+		t.Errorf("got event %+v", got.Event)
+	}
+}
+
+func TestNtfy(t *testing.T) {
+	tests := []struct {
+		trigger  string
+		token    string
+		priority string
+		tags     string
+	}{
+		{"added", "", "", "camera"},
+		{"failed", "tk_secret", "high", "warning"},
+		{"unreachable", "", "high", "warning"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.trigger, func(t *testing.T) {
+			srv, requests := recorder(t, http.StatusOK)
+			if err := (&Ntfy{URL: srv.URL + "/photos", Token: tt.token}).Send(context.Background(), testMessage(tt.trigger)); err != nil {
+				t.Fatal(err)
+			}
+			r := <-requests
+			if r.Path != "/photos" || string(r.Body) != testMessage(tt.trigger).Body {
+				t.Errorf("got body %q at %s", r.Body, r.Path)
+			}
+			if r.Header.Get("Title") != "Holiday: 2 new items" {
+				t.Errorf("got title %q", r.Header.Get("Title"))
+			}
+			if r.Header.Get("Priority") != tt.priority || r.Header.Get("Tags") != tt.tags {
+				t.Errorf("got priority %q and tags %q, want %q and %q", r.Header.Get("Priority"), r.Header.Get("Tags"), tt.priority, tt.tags)
+			}
+			wantAuth := ""
+			if tt.token != "" {
+				wantAuth = "Bearer " + tt.token
+			}
+			if r.Header.Get("Authorization") != wantAuth {
+				t.Errorf("got authorization %q, want %q", r.Header.Get("Authorization"), wantAuth)
+			}
+		})
+	}
+}
+
+func TestGotify(t *testing.T) {
+	tests := []struct {
+		trigger  string
+		priority int
+	}{
+		{"added", 5},
+		{"failed", 8},
+		{"empty", 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.trigger, func(t *testing.T) {
+			srv, requests := recorder(t, http.StatusOK)
+			// A trailing slash on the server URL must not double up
+			if err := (&Gotify{URL: srv.URL + "/", Token: "app-token"}).Send(context.Background(), testMessage(tt.trigger)); err != nil {
+				t.Fatal(err)
+			}
+			r := <-requests
+			if r.Path != "/message" || r.Header.Get("X-Gotify-Key") != "app-token" {
+				t.Errorf("got %s with key %q", r.Path, r.Header.Get("X-Gotify-Key"))
+			}
+			var got struct {
+				Title    string `json:"title"`
+				Message  string `json:"message"`
+				Priority int    `json:"priority"`
+			}
+			if err := json.Unmarshal(r.Body, &got); err != nil {
+				t.Fatalf("invalid JSON %s: %v", r.Body, err)
+			}
+			if got.Title != "Holiday: 2 new items" || got.Message != testMessage(tt.trigger).Body || got.Priority != tt.priority {
+				t.Errorf("got %+v, want priority %d", got, tt.priority)
+			}
+		})
+	}
+}
+
+func TestPostError(t *testing.T) {
+	srv, _ := recorder(t, http.StatusForbidden)
+	err := (&Webhook{URL: srv.URL}).Send(context.Background(), testMessage("failed"))
+	if err == nil || err.Error() != "403 Forbidden: denied" {
+		t.Errorf("got %v, want the status and response body", err)
+	}
+}
@@ -0,0 +1,189 @@
+// Package notify delivers sync notifications to webhooks, ntfy, Gotify and email, with
+// templated messages, per-album rate limiting and retried delivery.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Delivery settings
+const (
+	maxAttempts  = 4
+	baseBackoff  = 2 * time.Second
+	sendTimeout  = 30 * time.Second
+	queueSize    = 100
+	defaultTitle = `{{.Album}}: {{if eq .Trigger "unreachable"}}album unreachable{{else if eq .Trigger "failed"}}sync failed{{else if eq .Trigger "empty"}}no items found{{else}}{{.New}} new items{{end}}`
+	defaultBody  = `{{if .Error}}{{.Error}}
+{{end}}{{if .Total}}{{.New}} new, {{.Skipped}} skipped and {{.Failed}} failed of {{.Total}} items in {{.Duration}}.
+{{end}}{{.URL}}`
+)
+
+// Event is the outcome of one album sync and the data available to message templates
+type Event struct {
+	Trigger  string   `json:"trigger"`  // most severe trigger that matched
+	Triggers []string `json:"triggers"` // every trigger that matched, most severe first
+	Album    string   `json:"album"`    // title, or the URL if the album could not be fetched
+	AlbumID  string   `json:"albumId"`  // ID used by the HTTP API and metrics
+	URL      string   `json:"url"`
+	Status   string   `json:"status"` // "success", "partial" or "failed"
+	Total    int      `json:"total"`
+	New      int      `json:"new"` // added plus linked
+	Added    int      `json:"added"`
+	Linked   int      `json:"linked"`
+	Skipped  int      `json:"skipped"`
+	Failed   int      `json:"failed"`
+	Error    string   `json:"error,omitempty"`
+
+	Duration time.Duration `json:"-"` // rounded to seconds
+	Time     time.Time     `json:"time"`
+}
+
+// Message is a rendered notification
+type Message struct {
+	Title string
+	Body  string
+	Event Event
+}
+
+// Sender delivers a message over one channel
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Target is a configured notification: a channel, the triggers it is sent for and its templates
+type Target struct {
+	Name        string
+	Sender      Sender
+	Triggers    []string
+	Title       string // text/template, default if empty
+	Body        string // text/template, default if empty
+	MinInterval time.Duration
+}
+
+// Dispatcher renders and queues notifications and delivers them in the background, one at a time
+type Dispatcher struct {
+	logger *slog.Logger
+	queue  chan delivery
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	last map[string]time.Time // target/album/trigger -> last queued
+}
+
+type delivery struct {
+	target string
+	sender Sender
+	msg    Message
+}
+
+func NewDispatcher(logger *slog.Logger) *Dispatcher {
+	d := &Dispatcher{
+		logger: logger,
+		queue:  make(chan delivery, queueSize),
+		last:   make(map[string]time.Time),
+	}
+	go d.run()
+	return d
+}
+
+// Notify queues ev for t if one of its triggers matches. Notifications for the same album and
+// trigger within t.MinInterval of the previous one are dropped.
+func (d *Dispatcher) Notify(t Target, ev Event) {
+	var matched []string
+	for _, trigger := range ev.Triggers {
+		for _, want := range t.Triggers {
+			if trigger == want {
+				matched = append(matched, trigger)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+	ev.Trigger, ev.Triggers = matched[0], matched
+	logger := d.logger.With("notification", t.Name, "album", ev.URL, "trigger", ev.Trigger)
+
+	key := t.Name + "\x00" + ev.URL + "\x00" + ev.Trigger
+	d.mu.Lock()
+	if last, ok := d.last[key]; ok && ev.Time.Sub(last) < t.MinInterval {
+		d.mu.Unlock()
+		logger.Debug("Notification rate-limited", "last_sent", last.Format(time.RFC3339))
+		return
+	}
+	d.last[key] = ev.Time
+	d.mu.Unlock()
+
+	title, err := render(t.Title, defaultTitle, ev)
+	if err != nil {
+		logger.Error("Failed to render notification title", "error", err)
+		return
+	}
+	body, err := render(t.Body, defaultBody, ev)
+	if err != nil {
+		logger.Error("Failed to render notification message", "error", err)
+		return
+	}
+
+	d.wg.Add(1)
+	select {
+	case d.queue <- delivery{target: t.Name, sender: t.Sender, msg: Message{Title: title, Body: body, Event: ev}}:
+	default:
+		d.wg.Done()
+		logger.Warn("Notification queue full, dropping notification")
+	}
+}
+
+// Wait blocks until every queued notification has been delivered or given up on
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) run() {
+	for job := range d.queue {
+		d.deliver(job)
+		d.wg.Done()
+	}
+}
+
+// deliver sends a message, retrying with exponential backoff
+func (d *Dispatcher) deliver(job delivery) {
+	logger := d.logger.With("notification", job.target, "album", job.msg.Event.URL, "trigger", job.msg.Event.Trigger)
+	backoff := baseBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err := job.sender.Send(ctx, job.msg)
+		cancel()
+		if err == nil {
+			logger.Info("Notification sent")
+			return
+		}
+		if attempt == maxAttempts {
+			logger.Error("Failed to send notification, giving up", "error", err, "attempts", attempt)
+			return
+		}
+		logger.Warn("Failed to send notification, retrying", "error", err, "attempt", attempt, "sleep", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func render(text, fallback string, ev Event) (string, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New("notification").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ev); err != nil {
+		return "", fmt.Errorf("executing template: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSender records the messages it is asked to send
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func (s *fakeSender) Send(ctx context.Context, m Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func (s *fakeSender) messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.sent...)
+}
+
+func newTestDispatcher() *Dispatcher {
+	return NewDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestNotifyTriggers(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     []string // triggers of the target
+		matched  []string // triggers of the event
+		trigger  string   // empty if nothing is sent
+		triggers []string
+	}{
+		{"single match", []string{"failed", "empty"}, []string{"failed"}, "failed", []string{"failed"}},
+		{"most severe match first", []string{"added", "failed"}, []string{"unreachable", "failed", "added"}, "failed", []string{"failed", "added"}},
+		{"only a less severe match", []string{"added"}, []string{"failed", "added"}, "added", []string{"added"}},
+		{"no match", []string{"failed", "empty"}, []string{"added"}, "", nil},
+		{"no triggers", []string{"failed"}, nil, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDispatcher()
+			sender := &fakeSender{}
+			d.Notify(Target{Name: "n", Sender: sender, Triggers: tt.want}, Event{Triggers: tt.matched, Album: "Holiday", URL: "u", Time: time.Now()})
+			d.Wait()
+
+			sent := sender.messages()
+			if tt.trigger == "" {
+				if len(sent) != 0 {
+					t.Fatalf("sent %d messages, want none", len(sent))
+				}
+				return
+			}
+			if len(sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(sent))
+			}
+			ev := sent[0].Event
+			if ev.Trigger != tt.trigger || len(ev.Triggers) != len(tt.triggers) {
+				t.Fatalf("got trigger %q of %v, want %q of %v", ev.Trigger, ev.Triggers, tt.trigger, tt.triggers)
+			}
+			for i := range tt.triggers {
+				if ev.Triggers[i] != tt.triggers[i] {
+					t.Errorf("got triggers %v, want %v", ev.Triggers, tt.triggers)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyMinInterval(t *testing.T) {
+	d := newTestDispatcher()
+	sender := &fakeSender{}
+	target := Target{Name: "n", Sender: sender, Triggers: []string{"failed", "empty"}, MinInterval: time.Hour}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	event := func(url, trigger string, at time.Duration) Event {
+		return Event{Triggers: []string{trigger}, Album: url, URL: url, Time: start.Add(at)}
+	}
+
+	d.Notify(target, event("a", "failed", 0))
+	d.Notify(target, event("a", "failed", 30*time.Minute)) // dropped: same album and trigger
+	d.Notify(target, event("a", "empty", 31*time.Minute))  // another trigger
+	d.Notify(target, event("b", "failed", 32*time.Minute)) // another album
+	d.Notify(target, event("a", "failed", 61*time.Minute)) // interval since the first has passed
+	d.Notify(Target{Name: "other", Sender: sender, Triggers: []string{"failed"}, MinInterval: time.Hour}, event("a", "failed", 62*time.Minute))
+	d.Wait()
+
+	var got []string
+	for _, m := range sender.messages() {
+		got = append(got, m.Event.URL+"/"+m.Event.Trigger+"@"+m.Event.Time.Sub(start).String())
+	}
+	want := []string{"a/failed@0s", "a/empty@31m0s", "b/failed@32m0s", "a/failed@1h1m0s", "a/failed@1h2m0s"}
+	if len(got) != len(want) {
+		t.Fatalf("sent %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sent %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestNotifyTemplates(t *testing.T) {
+	ev := Event{
+		Triggers: []string{"failed"},
+		Album:    "Holiday",
+		URL:      "https://photos.app.goo.gl/abc",
+		Total:    3,
+		New:      1,
+		Skipped:  1,
+		Failed:   1,
+		Error:    "upload failed",
+		Duration: 4 * time.Second,
+		Time:     time.Now(),
+	}
+	tests := []struct {
+		name        string
+		title, body string
+		wantTitle   string
+		wantBody    string
+	}{
+		{"defaults", "", "", "Holiday: sync failed", "upload failed\n1 new, 1 skipped and 1 failed of 3 items in 4s.\nhttps://photos.app.goo.gl/abc"},
+		{"custom", "{{.Album}} ({{.Trigger}})", "{{.Failed}} of {{.Total}}", "Holiday (failed)", "1 of 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDispatcher()
+			sender := &fakeSender{}
+			d.Notify(Target{Name: "n", Sender: sender, Triggers: []string{"failed"}, Title: tt.title, Body: tt.body}, ev)
+			d.Wait()
+			sent := sender.messages()
+			if len(sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(sent))
+			}
+			if sent[0].Title != tt.wantTitle || sent[0].Body != tt.wantBody {
+				t.Errorf("got %q / %q, want %q / %q", sent[0].Title, sent[0].Body, tt.wantTitle, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestNotifyInvalidTemplate(t *testing.T) {
+	d := newTestDispatcher()
+	sender := &fakeSender{}
+	d.Notify(Target{Name: "n", Sender: sender, Triggers: []string{"failed"}, Title: "{{.Nope"}, Event{Triggers: []string{"failed"}, Time: time.Now()})
+	d.Wait()
+	if n := len(sender.messages()); n != 0 {
+		t.Errorf("sent %d messages for an unparseable template", n)
+	}
+}
@@ -74,6 +74,32 @@ func (t *Tracker) RecordItem(downloaded, uploaded int64, wasAdded bool, wasSkipp
 	}
 }
 
+// Snapshot is a point-in-time copy of a tracker's counters
+type Snapshot struct {
+	Total           int           `json:"total"`
+	Processed       int           `json:"processed"`
+	Added           int           `json:"added"`
+	Skipped         int           `json:"skipped"`
+	Failed          int           `json:"failed"`
+	BytesDownloaded int64         `json:"bytesDownloaded"`
+	BytesUploaded   int64         `json:"bytesUploaded"`
+	Elapsed         time.Duration `json:"-"`
+}
+
+// Snapshot returns the current counters; safe to call while items are being recorded
+func (t *Tracker) Snapshot() Snapshot {
+	return Snapshot{
+		Total:           t.totalItems,
+		Processed:       int(t.processedItems.Load()),
+		Added:           int(t.addedItems.Load()),
+		Skipped:         int(t.skippedItems.Load()),
+		Failed:          int(t.failedItems.Load()),
+		BytesDownloaded: t.bytesDownloaded.Load(),
+		BytesUploaded:   t.bytesUploaded.Load(),
+		Elapsed:         time.Since(t.startTime),
+	}
+}
+
 // Start begins periodic progress printing (only in non-debug mode)
 func (t *Tracker) Start() {
 	if t.debug {
@@ -0,0 +1,182 @@
+// Package report writes a JSON and a CSV file per album sync, listing the outcome of every item.
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Item outcomes
+const (
+	Added           = "added"               // uploaded to Immich
+	Linked          = "linked"              // already in Immich, added to the album
+	Restored        = "restored"            // restored from the Immich trash and added to the album
+	SkippedExisting = "skipped-existing"    // already in the album
+	SkippedVideo    = "skipped-video"       // skipVideos is set
+	SkippedNoDate   = "skipped-no-date"     // strictMetadata is set and no capture date was found
+	SkippedTrashed  = "skipped-trashed"     // trashed in Immich and trashPolicy is respect
+	SkippedDead     = "skipped-dead-letter" // failed too often, see the "retries" command
+	Failed          = "failed"
+)
+
+// Outcomes lists every item outcome in report order
+var Outcomes = []string{Added, Linked, Restored, SkippedExisting, SkippedVideo, SkippedNoDate, SkippedTrashed, SkippedDead, Failed}
+
+// Item is the outcome of one album item
+type Item struct {
+	ItemID          string  `json:"itemId"`
+	URL             string  `json:"url"`
+	Outcome         string  `json:"outcome"`
+	Error           string  `json:"error,omitempty"`
+	BytesDownloaded int64   `json:"bytesDownloaded"`
+	BytesUploaded   int64   `json:"bytesUploaded"`
+	Duration        float64 `json:"durationSeconds"`
+}
+
+// Summary totals the items of a run
+type Summary struct {
+	Total           int            `json:"total"`
+	Outcomes        map[string]int `json:"outcomes"`
+	BytesDownloaded int64          `json:"bytesDownloaded"`
+	BytesUploaded   int64          `json:"bytesUploaded"`
+}
+
+// Report is one sync of one album
+type Report struct {
+	Album      string    `json:"album"`   // title, or the URL if the album could not be fetched
+	AlbumID    string    `json:"albumId"` // ID used by the HTTP API and metrics
+	URL        string    `json:"url"`
+	Status     string    `json:"status"` // "success", "partial" or "failed"
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Duration   float64   `json:"durationSeconds"`
+	Summary    Summary   `json:"summary"`
+	Items      []Item    `json:"items"`
+}
+
+// New returns a report of items with its summary filled in
+func New(items []Item) *Report {
+	r := &Report{
+		Items:   items,
+		Summary: Summary{Total: len(items), Outcomes: make(map[string]int, len(Outcomes))},
+	}
+	if r.Items == nil {
+		r.Items = []Item{}
+	}
+	for _, outcome := range Outcomes {
+		r.Summary.Outcomes[outcome] = 0
+	}
+	for _, item := range items {
+		r.Summary.Outcomes[item.Outcome]++
+		r.Summary.BytesDownloaded += item.BytesDownloaded
+		r.Summary.BytesUploaded += item.BytesUploaded
+	}
+	return r
+}
+
+// name is the file name of the report without extension: <started, UTC>_<album ID>
+func (r *Report) name() string {
+	return r.StartedAt.UTC().Format("20060102T150405Z") + "_" + r.AlbumID
+}
+
+// Write saves the report as <name>.json and <name>.csv in dir and returns the JSON path
+func (r *Report) Write(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	base := filepath.Join(dir, r.name())
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := writeFile(base+".json", func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	}); err != nil {
+		return "", err
+	}
+
+	if err := writeFile(base+".csv", r.writeCSV); err != nil {
+		return "", err
+	}
+	return base + ".json", nil
+}
+
+// writeCSV writes one row per item
+func (r *Report) writeCSV(f *os.File) error {
+	w := csv.NewWriter(f)
+	w.Write([]string{"album", "item_id", "url", "outcome", "error", "bytes_downloaded", "bytes_uploaded", "duration_seconds"})
+	for _, item := range r.Items {
+		w.Write([]string{
+			r.Album,
+			item.ItemID,
+			item.URL,
+			item.Outcome,
+			item.Error,
+			strconv.FormatInt(item.BytesDownloaded, 10),
+			strconv.FormatInt(item.BytesUploaded, 10),
+			strconv.FormatFloat(item.Duration, 'f', 3, 64),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeFile writes through a temp file so readers never see a partial report
+func writeFile(path string, write func(*os.File) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	return nil
+}
+
+// Prune deletes all but the newest keep reports of an album
+func Prune(dir, albumID string, keep int) error {
+	matches, err := filepath.Glob(filepath.Join(dir, "*_"+albumID+".json"))
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, m := range matches {
+		name := strings.TrimSuffix(filepath.Base(m), ".json")
+		// The glob also matches IDs ending in "_<albumID>"; the timestamp has no underscore
+		if _, id, ok := strings.Cut(name, "_"); ok && id == albumID {
+			names = append(names, name)
+		}
+	}
+	if len(names) <= keep {
+		return nil
+	}
+	sort.Strings(names) // timestamps sort chronologically
+	for _, name := range names[:len(names)-keep] {
+		for _, ext := range []string{".json", ".csv"} {
+			if err := os.Remove(filepath.Join(dir, name+ext)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func testReport(albumID string, started time.Time) *Report {
+	r := New([]Item{
+		{ItemID: "a", URL: "https://example.com/a", Outcome: Added, BytesDownloaded: 100, BytesUploaded: 120, Duration: 1.5},
+		{ItemID: "b", URL: "https://example.com/b", Outcome: Linked, BytesDownloaded: 50},
+		{ItemID: "c", URL: "https://example.com/c", Outcome: Failed, Error: "upload failed: 500, \"internal\""},
+	})
+	r.Album = "Holiday, 2024"
+	r.AlbumID = albumID
+	r.URL = "https://photos.app.goo.gl/abc"
+	r.Status = "partial"
+	r.StartedAt = started
+	r.FinishedAt = started.Add(3 * time.Second)
+	r.Duration = 3
+	return r
+}
+
+func TestNew(t *testing.T) {
+	r := New(nil)
+	if r.Items == nil || r.Summary.Total != 0 {
+		t.Errorf("got items %v and total %d", r.Items, r.Summary.Total)
+	}
+	for _, outcome := range Outcomes {
+		if n, ok := r.Summary.Outcomes[outcome]; !ok || n != 0 {
+			t.Errorf("outcome %s: got %d (present %v), want 0", outcome, n, ok)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reports")
+	started := time.Date(2024, 5, 1, 14, 30, 0, 0, time.FixedZone("", 2*3600))
+	path, err := testReport("abc123", started).Write(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "20240501T123000Z_abc123.json"); path != want {
+		t.Errorf("got %s, want %s", path, want)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Album != "Holiday, 2024" || got.Status != "partial" || !got.StartedAt.Equal(started) || len(got.Items) != 3 {
+		t.Errorf("got %+v", got)
+	}
+	if got.Items[2].Error != "upload failed: 500, \"internal\"" {
+		t.Errorf("got error %q", got.Items[2].Error)
+	}
+	summary := got.Summary
+	if summary.Total != 3 || summary.BytesDownloaded != 150 || summary.BytesUploaded != 120 {
+		t.Errorf("got summary %+v", summary)
+	}
+	if summary.Outcomes[Added] != 1 || summary.Outcomes[Linked] != 1 || summary.Outcomes[Failed] != 1 || summary.Outcomes[SkippedExisting] != 0 || len(summary.Outcomes) != len(Outcomes) {
+		t.Errorf("got outcomes %v", summary.Outcomes)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "20240501T123000Z_abc123.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"album", "item_id", "url", "outcome", "error", "bytes_downloaded", "bytes_uploaded", "duration_seconds"},
+		{"Holiday, 2024", "a", "https://example.com/a", "added", "", "100", "120", "1.500"},
+		{"Holiday, 2024", "b", "https://example.com/b", "linked", "", "50", "0", "0.000"},
+		{"Holiday, 2024", "c", "https://example.com/c", "failed", "upload failed: 500, \"internal\"", "0", "0", "0.000"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got rows %q, want %q", rows, want)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("got %d files, want the JSON and CSV report only", len(entries))
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		for _, id := range []string{"abc", "x_abc", "other"} {
+			if _, err := testReport(id, start.Add(time.Duration(i)*time.Hour)).Write(dir); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// Not a report, must survive
+	if err := os.WriteFile(filepath.Join(dir, "notes_abc.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Prune(dir, "abc", 2); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	var want []string
+	for i := 0; i < 4; i++ {
+		stamp := start.Add(time.Duration(i) * time.Hour).Format("20060102T150405Z")
+		for _, id := range []string{"abc", "x_abc", "other"} {
+			if id == "abc" && i < 2 {
+				continue // the two oldest reports of the album
+			}
+			want = append(want, stamp+"_"+id+".csv", stamp+"_"+id+".json")
+		}
+	}
+	want = append(want, "notes_abc.txt")
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got files %v, want %v", got, want)
+	}
+
+	// Keeping more reports than exist deletes nothing
+	if err := Prune(dir, "other", 10); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != len(want) {
+		t.Errorf("got %d files after a no-op prune, want %d", len(entries), len(want))
+	}
+}
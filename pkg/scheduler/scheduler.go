@@ -0,0 +1,252 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RunFunc executes the job identified by key and returns when it should run next
+type RunFunc func(key string) time.Time
+
+// Entry describes one scheduled job
+type Entry struct {
+	Key     string
+	Next    time.Time
+	Running bool
+}
+
+type job struct {
+	key     string
+	next    time.Time
+	running bool
+	removed bool
+	index   int // position in the heap, -1 while running
+}
+
+// jobHeap is a min-heap of jobs ordered by next run time
+type jobHeap []*job
+
+func (h jobHeap) Len() int           { return len(h) }
+func (h jobHeap) Less(i, j int) bool { return h[i].next.Before(h[j].next) }
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *jobHeap) Push(x interface{}) {
+	j := x.(*job)
+	j.index = len(*h)
+	*h = append(*h, j)
+}
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	j := old[n-1]
+	old[n-1] = nil
+	j.index = -1
+	*h = old[:n-1]
+	return j
+}
+
+// Scheduler runs jobs on individual timers held in a priority queue.
+// At most workers jobs run at once; each job is rescheduled as soon as its own run finishes.
+type Scheduler struct {
+	run RunFunc
+
+	mu      sync.Mutex
+	queue   jobHeap
+	jobs    map[string]*job
+	workers int
+	active  int
+	wake    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func New(workers int, run RunFunc) *Scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Scheduler{
+		run:     run,
+		jobs:    make(map[string]*job),
+		workers: workers,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Add schedules a job, or moves an idle job to the given time
+func (s *Scheduler) Add(key string, next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[key]; ok {
+		j.removed = false
+		if !j.running {
+			j.next = next
+			heap.Fix(&s.queue, j.index)
+		}
+	} else {
+		j := &job{key: key, next: next}
+		s.jobs[key] = j
+		heap.Push(&s.queue, j)
+	}
+	s.signal()
+}
+
+// Trigger makes a job due immediately. Returns false if the key is unknown.
+// A running job is not interrupted; it is rescheduled normally when it finishes.
+func (s *Scheduler) Trigger(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[key]
+	if !ok || j.removed {
+		return false
+	}
+	if !j.running {
+		j.next = time.Now()
+		heap.Fix(&s.queue, j.index)
+		s.signal()
+	}
+	return true
+}
+
+// Remove unschedules a job. A running job finishes but is not rescheduled.
+func (s *Scheduler) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[key]
+	if !ok {
+		return
+	}
+	if j.running {
+		j.removed = true
+		return
+	}
+	heap.Remove(&s.queue, j.index)
+	delete(s.jobs, key)
+	s.signal()
+}
+
+// SetWorkers changes how many jobs may run concurrently
+func (s *Scheduler) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.mu.Lock()
+	s.workers = n
+	s.signal()
+	s.mu.Unlock()
+}
+
+// TryAcquire takes a worker slot for work outside the queue, such as retries, if one is free.
+// Call Release when done.
+func (s *Scheduler) TryAcquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active >= s.workers {
+		return false
+	}
+	s.active++
+	return true
+}
+
+// Release frees a worker slot taken with TryAcquire
+func (s *Scheduler) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	s.signal()
+}
+
+// Upcoming returns all jobs ordered by next run time, running jobs first
+func (s *Scheduler) Upcoming() []Entry {
+	s.mu.Lock()
+	entries := make([]Entry, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		if j.removed {
+			continue
+		}
+		entries = append(entries, Entry{Key: j.key, Next: j.next, Running: j.running})
+	}
+	s.mu.Unlock()
+	sort.Slice(entries, func(i, k int) bool {
+		if entries[i].Running != entries[k].Running {
+			return entries[i].Running
+		}
+		return entries[i].Next.Before(entries[k].Next)
+	})
+	return entries
+}
+
+// Run dispatches due jobs until ctx is cancelled, then waits for running jobs to finish
+func (s *Scheduler) Run(ctx context.Context) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		// Start every due job a worker slot is available for
+		for s.queue.Len() > 0 && s.active < s.workers && !s.queue[0].next.After(time.Now()) {
+			j := heap.Pop(&s.queue).(*job)
+			j.running = true
+			s.active++
+			s.wg.Add(1)
+			go s.execute(j)
+		}
+		// Without a free slot or a queued job only a signal can make progress
+		var wait time.Duration
+		hasNext := s.queue.Len() > 0 && s.active < s.workers
+		if hasNext {
+			wait = max(time.Until(s.queue[0].next), 0)
+		}
+		s.mu.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		var timerC <-chan time.Time
+		if hasNext {
+			timer.Reset(wait)
+			timerC = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-timerC:
+		case <-s.wake:
+		}
+	}
+}
+
+// execute runs a job and puts it back into the queue at the time it returned
+func (s *Scheduler) execute(j *job) {
+	defer s.wg.Done()
+	next := s.run(j.key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	j.running = false
+	if j.removed {
+		delete(s.jobs, j.key)
+	} else {
+		j.next = next
+		heap.Push(&s.queue, j)
+	}
+	s.signal()
+}
+
+// signal wakes the dispatch loop without blocking
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
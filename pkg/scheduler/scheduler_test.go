@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder counts runs per key and reschedules every job after the given interval
+type recorder struct {
+	mu       sync.Mutex
+	runs     map[string]int
+	running  int
+	peak     int
+	interval time.Duration
+	hold     time.Duration
+}
+
+func (r *recorder) run(key string) time.Time {
+	r.mu.Lock()
+	r.runs[key]++
+	r.running++
+	r.peak = max(r.peak, r.running)
+	r.mu.Unlock()
+
+	time.Sleep(r.hold)
+
+	r.mu.Lock()
+	r.running--
+	r.mu.Unlock()
+	return time.Now().Add(r.interval)
+}
+
+func (r *recorder) count(key string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.runs[key]
+}
+
+func start(t *testing.T, s *Scheduler) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRunPastDue(t *testing.T) {
+	r := &recorder{runs: make(map[string]int), interval: 20 * time.Millisecond}
+	s := New(1, r.run)
+	// Jobs that became due while the dispatcher was busy must still arm the timer
+	s.Add("a", time.Now().Add(-time.Hour))
+	s.Add("b", time.Now().Add(-time.Minute))
+	start(t, s)
+
+	waitFor(t, "repeated runs", func() bool { return r.count("a") >= 3 && r.count("b") >= 3 })
+}
+
+func TestWorkers(t *testing.T) {
+	r := &recorder{runs: make(map[string]int), interval: time.Hour, hold: 20 * time.Millisecond}
+	s := New(2, r.run)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		s.Add(key, time.Now())
+	}
+	start(t, s)
+
+	waitFor(t, "all jobs", func() bool {
+		for _, key := range []string{"a", "b", "c", "d", "e"} {
+			if r.count(key) == 0 {
+				return false
+			}
+		}
+		return true
+	})
+	if r.peak > 2 {
+		t.Errorf("%d jobs ran at once, want at most 2", r.peak)
+	}
+}
+
+func TestTryAcquire(t *testing.T) {
+	r := &recorder{runs: make(map[string]int), interval: time.Hour}
+	s := New(1, r.run)
+	start(t, s)
+
+	if !s.TryAcquire() {
+		t.Fatal("TryAcquire failed with a free slot")
+	}
+	if s.TryAcquire() {
+		t.Fatal("TryAcquire succeeded without a free slot")
+	}
+	s.Add("a", time.Now())
+	time.Sleep(20 * time.Millisecond)
+	if r.count("a") != 0 {
+		t.Fatal("job ran while its slot was taken")
+	}
+	s.Release()
+	waitFor(t, "job after Release", func() bool { return r.count("a") == 1 })
+}
+
+func TestTriggerRemove(t *testing.T) {
+	r := &recorder{runs: make(map[string]int), interval: time.Hour}
+	s := New(1, r.run)
+	s.Add("a", time.Now().Add(time.Hour))
+	s.Add("b", time.Now().Add(time.Hour))
+	start(t, s)
+
+	if !s.Trigger("a") {
+		t.Fatal("Trigger of a scheduled job failed")
+	}
+	waitFor(t, "triggered job", func() bool { return r.count("a") == 1 })
+	if r.count("b") != 0 {
+		t.Error("untriggered job ran")
+	}
+
+	s.Remove("b")
+	if s.Trigger("b") {
+		t.Error("Trigger of a removed job succeeded")
+	}
+	entries := s.Upcoming()
+	if len(entries) != 1 || entries[0].Key != "a" || entries[0].Next.Before(time.Now().Add(50*time.Minute)) {
+		t.Errorf("Upcoming = %+v", entries)
+	}
+}
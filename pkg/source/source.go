@@ -0,0 +1,53 @@
+package source
+
+import (
+	"io"
+	"time"
+)
+
+// Item is a single media entry of a shared album, independent of the provider
+type Item struct {
+	ID          string
+	URL         string
+	Width       int
+	Height      int
+	TakenAt     time.Time
+	Description string
+}
+
+// Album holds the metadata and full item list of a shared album
+type Album struct {
+	ID    string
+	Title string
+	Items []Item
+}
+
+// Media is a downloaded original, ready to be streamed to Immich.
+// The caller must close Body.
+type Media struct {
+	Body     io.ReadCloser
+	Size     int64
+	Ext      string // e.g. ".jpg"
+	IsVideo  bool
+	Checksum string // hex SHA-1 of the content, empty if not computed
+
+	// LiveVideo is the motion part of a motion photo, uploaded separately and linked
+	// to the still as an Immich live photo. The caller must close its Body too.
+	LiveVideo *Media
+}
+
+// DownloadOptions tunes how a Source downloads media
+type DownloadOptions struct {
+	// Spool buffers the download on disk and computes Media.Checksum before returning
+	Spool bool
+	// SplitMotion separates motion photos into a still image and Media.LiveVideo
+	SplitMotion bool
+}
+
+// Source is implemented by every shared-album provider (Google Photos, ...)
+type Source interface {
+	// FetchAlbum returns the album metadata together with all of its items
+	FetchAlbum(albumURL string) (*Album, error)
+	// Download streams the original media of an item
+	Download(item Item, opts DownloadOptions) (*Media, error)
+}
@@ -0,0 +1,210 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const retriesFile = "retries.json"
+
+// Failure is an item that failed to sync: queued for a retry, or dead-lettered after too many attempts
+type Failure struct {
+	Album       string    `json:"album"` // album URL
+	ItemID      string    `json:"itemId"`
+	URL         string    `json:"url,omitempty"`
+	Class       string    `json:"class"` // what failed, e.g. "download" or "upload"
+	Error       string    `json:"error"`
+	Attempts    int       `json:"attempts"`
+	FirstFailed time.Time `json:"firstFailed"`
+	LastFailed  time.Time `json:"lastFailed"`
+	NextRetry   time.Time `json:"nextRetry,omitempty"` // zero once dead-lettered
+	Dead        bool      `json:"dead,omitempty"`
+}
+
+// RetryQueue persists failed items across restarts. Put and Delete only change the queue in
+// memory until Save, so an album run writes the file once; Update writes immediately. Changes
+// made by another process, such as the "retries" command, are picked up by Refresh, List,
+// Update and Save, and unsaved changes are applied on top of them.
+type RetryQueue struct {
+	path    string
+	mu      sync.Mutex
+	items   map[string]Failure  // keyed by album URL and item ID
+	pending map[string]*Failure // unsaved changes by key, nil for a deletion
+	modTime time.Time           // of the file when last read or written
+}
+
+func retryKey(album, itemID string) string {
+	return album + "\x00" + itemID
+}
+
+// OpenRetryQueue loads the retry queue from dir, creating the directory if needed
+func OpenRetryQueue(dir string) (*RetryQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating state directory: %w", err)
+	}
+	q := &RetryQueue{
+		path:    filepath.Join(dir, retriesFile),
+		items:   make(map[string]Failure),
+		pending: make(map[string]*Failure),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// load re-reads the file if it changed since it was last read or written, keeping unsaved changes
+func (q *RetryQueue) load() error {
+	info, err := os.Stat(q.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading retry queue: %w", err)
+	}
+	if info.ModTime().Equal(q.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(q.path)
+	if err != nil {
+		return fmt.Errorf("error reading retry queue: %w", err)
+	}
+	var list []Failure
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("error parsing retry queue %s: %w", q.path, err)
+	}
+	q.items = make(map[string]Failure, len(list))
+	for _, f := range list {
+		q.items[retryKey(f.Album, f.ItemID)] = f
+	}
+	for key, f := range q.pending {
+		if f == nil {
+			delete(q.items, key)
+		} else {
+			q.items[key] = *f
+		}
+	}
+	q.modTime = info.ModTime()
+	return nil
+}
+
+// save writes the whole queue, including any unsaved changes
+func (q *RetryQueue) save() error {
+	data, err := json.MarshalIndent(q.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(q.path, data); err != nil {
+		return fmt.Errorf("error writing retry queue: %w", err)
+	}
+	if info, err := os.Stat(q.path); err == nil {
+		q.modTime = info.ModTime()
+	}
+	q.pending = make(map[string]*Failure)
+	return nil
+}
+
+// list returns all failures sorted by album and item ID
+func (q *RetryQueue) list() []Failure {
+	out := make([]Failure, 0, len(q.items))
+	for _, f := range q.items {
+		out = append(out, f)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Album != out[j].Album {
+			return out[i].Album < out[j].Album
+		}
+		return out[i].ItemID < out[j].ItemID
+	})
+	return out
+}
+
+// Get returns the failure recorded for an item of an album, as of the last Refresh
+func (q *RetryQueue) Get(album, itemID string) (Failure, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	f, ok := q.items[retryKey(album, itemID)]
+	return f, ok
+}
+
+// Refresh picks up changes another process made to the file since it was last read or written
+func (q *RetryQueue) Refresh() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.load()
+}
+
+// List returns all queued and dead-lettered failures, sorted by album and item ID
+func (q *RetryQueue) List() ([]Failure, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q.list(), nil
+}
+
+// Put stores or replaces a failure until the next Save
+func (q *RetryQueue) Put(f Failure) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := retryKey(f.Album, f.ItemID)
+	q.items[key] = f
+	q.pending[key] = &f
+}
+
+// Delete removes the failure of an item, if any, until the next Save
+func (q *RetryQueue) Delete(album, itemID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := retryKey(album, itemID)
+	if _, ok := q.items[key]; !ok {
+		return
+	}
+	delete(q.items, key)
+	q.pending[key] = nil
+}
+
+// Save writes the queue to disk if it has unsaved changes, merged into the current file
+func (q *RetryQueue) Save() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return nil
+	}
+	if err := q.load(); err != nil {
+		return err
+	}
+	return q.save()
+}
+
+// Update calls fn for every failure accepted by match and keeps the modified failure, or
+// deletes it if fn returns false. It returns the number of matching failures.
+func (q *RetryQueue) Update(match func(Failure) bool, fn func(*Failure) bool) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.load(); err != nil {
+		return 0, err
+	}
+	n := 0
+	for key, f := range q.items {
+		if !match(f) {
+			continue
+		}
+		n++
+		if fn(&f) {
+			q.items[key] = f
+		} else {
+			delete(q.items, key)
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, q.save()
+}
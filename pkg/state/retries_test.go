@@ -0,0 +1,107 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetryQueueBatchesSaves(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenRetryQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	q.Put(Failure{Album: "a", ItemID: "1", Attempts: 1, LastFailed: now})
+	q.Put(Failure{Album: "a", ItemID: "2", Attempts: 1, LastFailed: now})
+	q.Delete("a", "2")
+	q.Delete("a", "missing")
+	if _, err := os.Stat(filepath.Join(dir, retriesFile)); !os.IsNotExist(err) {
+		t.Fatalf("queue written before Save: %v", err)
+	}
+	if f, ok := q.Get("a", "1"); !ok || f.Attempts != 1 {
+		t.Errorf("unsaved failure not visible: %+v", f)
+	}
+
+	if err := q.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenRetryQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := reopened.List()
+	if len(list) != 1 || list[0].ItemID != "1" || !list[0].LastFailed.Equal(now) {
+		t.Errorf("saved %+v, want item 1 only", list)
+	}
+
+	// Nothing to save: the file is left alone
+	info, _ := os.Stat(filepath.Join(dir, retriesFile))
+	time.Sleep(10 * time.Millisecond)
+	if err := q.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.Stat(filepath.Join(dir, retriesFile)); !after.ModTime().Equal(info.ModTime()) {
+		t.Error("queue rewritten without changes")
+	}
+}
+
+func TestRetryQueueMergesOtherProcess(t *testing.T) {
+	dir := t.TempDir()
+	daemon, err := OpenRetryQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon.Put(Failure{Album: "a", ItemID: "dead", Dead: true})
+	daemon.Put(Failure{Album: "a", ItemID: "other", Dead: true})
+	if err := daemon.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The "retries" command revives a dead letter while the daemon has unsaved changes
+	cli, err := OpenRetryQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon.Put(Failure{Album: "a", ItemID: "new", Attempts: 1})
+	daemon.Delete("a", "other")
+	time.Sleep(10 * time.Millisecond) // a distinct modification time
+	n, err := cli.Update(func(f Failure) bool { return f.ItemID == "dead" }, func(f *Failure) bool {
+		f.Dead = false
+		return true
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("updated %d: %v", n, err)
+	}
+
+	// Get answers from memory until the next Refresh
+	if f, _ := daemon.Get("a", "dead"); !f.Dead {
+		t.Error("Get re-read the file")
+	}
+	if err := daemon.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := daemon.Get("a", "dead"); f.Dead {
+		t.Error("Refresh did not pick up the change")
+	}
+	if _, ok := daemon.Get("a", "new"); !ok {
+		t.Error("Refresh dropped an unsaved failure")
+	}
+	if _, ok := daemon.Get("a", "other"); ok {
+		t.Error("Refresh undid an unsaved deletion")
+	}
+
+	if err := daemon.Save(); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := cli.List()
+	got := make(map[string]bool)
+	for _, f := range list {
+		got[f.ItemID] = f.Dead
+	}
+	if len(got) != 2 || got["dead"] || got["new"] {
+		t.Errorf("saved %+v, want the revived item and the new failure", list)
+	}
+}
@@ -0,0 +1,91 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const runsFile = "runs.json"
+
+// AlbumRun is the persisted schedule and outcome of an album's most recent sync
+type AlbumRun struct {
+	LastRun     time.Time     `json:"lastRun"`
+	LastSuccess time.Time     `json:"lastSuccess,omitempty"`
+	LastResult  string        `json:"lastResult"` // "success", "partial" or "failed"
+	LastError   string        `json:"lastError,omitempty"`
+	Title       string        `json:"title,omitempty"`
+	Total       int           `json:"total"`
+	Added       int           `json:"added"`
+	Skipped     int           `json:"skipped"`
+	Failed      int           `json:"failed"`
+	Duration    time.Duration `json:"duration"`
+	NextRun     time.Time     `json:"nextRun"`
+	Schedule    string        `json:"schedule,omitempty"` // the album schedule NextRun was computed with
+}
+
+// RunLog persists per-album run results and next-run times across restarts
+type RunLog struct {
+	path   string
+	mu     sync.Mutex
+	albums map[string]AlbumRun
+}
+
+// OpenRunLog loads the run log from dir, creating the directory if needed
+func OpenRunLog(dir string) (*RunLog, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating state directory: %w", err)
+	}
+	l := &RunLog{
+		path:   filepath.Join(dir, runsFile),
+		albums: make(map[string]AlbumRun),
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return l, nil
+		}
+		return nil, fmt.Errorf("error reading run log: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.albums); err != nil {
+		return nil, fmt.Errorf("error parsing run log %s: %w", l.path, err)
+	}
+	return l, nil
+}
+
+// Get returns the last run of an album
+func (l *RunLog) Get(album string) (AlbumRun, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r, ok := l.albums[album]
+	return r, ok
+}
+
+// All returns a copy of all recorded runs keyed by album URL
+func (l *RunLog) All() map[string]AlbumRun {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make(map[string]AlbumRun, len(l.albums))
+	for k, v := range l.albums {
+		out[k] = v
+	}
+	return out
+}
+
+// Put records a run and writes the log to disk
+func (l *RunLog) Put(album string, r AlbumRun) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.albums[album] = r
+	data, err := json.MarshalIndent(l.albums, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(l.path, data); err != nil {
+		return fmt.Errorf("error writing run log: %w", err)
+	}
+	return nil
+}
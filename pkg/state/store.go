@@ -0,0 +1,143 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const storeFile = "items.json"
+
+// Record maps one source item to the Immich asset it was synced to
+type Record struct {
+	AssetID  string    `json:"assetId"`
+	Checksum string    `json:"checksum,omitempty"` // hex SHA-1 of the uploaded file, if known
+	Size     int64     `json:"size,omitempty"`
+	TakenAt  time.Time `json:"takenAt,omitempty"`
+	LastSeen time.Time `json:"lastSeen"`
+	Uploaded bool      `json:"uploaded,omitempty"` // the asset was uploaded by this tool, not linked or restored
+	Skipped  string    `json:"skipped,omitempty"`  // report outcome of an item deliberately not synced, e.g. "skipped-video"
+}
+
+// Store is a small embedded key-value store persisted as JSON in the state directory.
+// Records are keyed by album URL and source item ID.
+type Store struct {
+	path   string
+	mu     sync.Mutex
+	albums map[string]map[string]Record
+	dirty  bool
+}
+
+// Open loads the store from dir, creating the directory if needed
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating state directory: %w", err)
+	}
+	s := &Store{
+		path:   filepath.Join(dir, storeFile),
+		albums: make(map[string]map[string]Record),
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("error reading state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.albums); err != nil {
+		return nil, fmt.Errorf("error parsing state %s: %w", s.path, err)
+	}
+	return s, nil
+}
+
+// Get returns the record for an item of an album
+func (s *Store) Get(album, itemID string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.albums[album][itemID]
+	return r, ok
+}
+
+// Put stores or replaces the record for an item of an album
+func (s *Store) Put(album, itemID string, r Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items, ok := s.albums[album]
+	if !ok {
+		items = make(map[string]Record)
+		s.albums[album] = items
+	}
+	items[itemID] = r
+	s.dirty = true
+}
+
+// Touch updates the last-seen time of an existing record
+func (s *Store) Touch(album, itemID string, seen time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.albums[album][itemID]; ok {
+		r.LastSeen = seen
+		s.albums[album][itemID] = r
+		s.dirty = true
+	}
+}
+
+// Delete removes the record for an item of an album
+func (s *Store) Delete(album, itemID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.albums[album][itemID]; ok {
+		delete(s.albums[album], itemID)
+		s.dirty = true
+	}
+}
+
+// Items returns a copy of all records of an album
+func (s *Store) Items(album string) map[string]Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]Record, len(s.albums[album]))
+	for id, r := range s.albums[album] {
+		out[id] = r
+	}
+	return out
+}
+
+// Save writes the store to disk if it changed, replacing the file atomically
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	data, err := json.Marshal(s.albums)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("error writing state: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}